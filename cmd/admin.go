@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/store"
+)
+
+func runAdmin(databaseURL string, args []string) {
+	if len(args) != 2 || (args[0] != "grant" && args[0] != "revoke") {
+		fmt.Fprintln(os.Stderr, "usage: photo-booth admin grant|revoke <username>")
+		os.Exit(2)
+	}
+
+	db, d := internal.InitDB(databaseURL)
+	defer db.Close()
+	users := store.NewSQL(db, d).Users
+
+	user, err := users.GetByUsername(args[1])
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %v", args[1], err)
+	}
+
+	admin := args[0] == "grant"
+	if err := users.SetAdmin(user.ID, admin); err != nil {
+		log.Fatalf("Failed to update user %s: %v", user.Username, err)
+	}
+	if admin {
+		fmt.Printf("%s is now an admin\n", user.Username)
+	} else {
+		fmt.Printf("%s is no longer an admin\n", user.Username)
+	}
+}
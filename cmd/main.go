@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
-	"text/template"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"photo-booth.com/controllers"
 	"photo-booth.com/internal"
+	"photo-booth.com/internal/mail"
+	"photo-booth.com/internal/ratelimit"
+	"photo-booth.com/internal/sso"
+	"photo-booth.com/internal/storage"
+	"photo-booth.com/internal/store"
 )
 
 func main() {
@@ -21,66 +31,81 @@ func main() {
 	}
 	port = ":" + port
 
-	if _, err := os.Stat("data"); os.IsNotExist(err) {
-		if err := os.Mkdir("data", os.ModePerm); err != nil {
-			log.Fatalf("Failed to create data directory: %v", err)
-		}
+	databaseURL := os.Getenv("DATABASE_URL")
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(databaseURL, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		runAdmin(databaseURL, os.Args[2:])
+		return
 	}
 
-	internal.InitDB("data/photo-booth.db")
-	defer internal.DB.Close()
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		log.Fatal("JWT_SECRET is not set in .env file")
+	}
 
-	mux := http.NewServeMux()
+	db, d := internal.InitDB(databaseURL)
+	defer db.Close()
 
-	wrappedMux := internal.AuthMiddleware(mux)
+	files, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
 
-	sfs := http.FileServer(http.Dir("./static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", sfs))
+	mailer, err := mail.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
 
-	ufs := http.FileServer(http.Dir("./uploads"))
-	mux.Handle("/uploads/", http.StripPrefix("/uploads/", ufs))
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost" + port
+	}
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		authenticated, _ := r.Context().Value(internal.AuthenticatedKey).(bool)
+	providers, err := sso.FromEnv(baseURL)
+	if err != nil {
+		log.Fatalf("Failed to configure sign-in providers: %v", err)
+	}
 
-		tmpl, err := template.ParseFiles("templates/index.html")
-		if err != nil {
-			http.Error(w, "Unable to load index page", http.StatusInternalServerError)
-			return
-		}
+	stores := store.NewSQL(db, d)
+	if err := internal.SeedOverlays(stores.Overlays, files); err != nil {
+		log.Fatalf("Failed to import bundled overlays: %v", err)
+	}
+	internal.Store = internal.NewSessionStore(stores.Sessions, []byte(secret))
+	internal.Store.Options.Secure = strings.HasPrefix(baseURL, "https://")
+	outbox := mail.NewOutbox(stores.Outbox, mailer)
 
-		data := struct {
-			Authenticated bool
-		}{
-			Authenticated: authenticated,
-		}
-		if err := tmpl.Execute(w, data); err != nil {
-			http.Error(w, "Unable to render template", http.StatusInternalServerError)
-		}
-	})
-	mux.HandleFunc("/register", controllers.RegisterHandler)
-	mux.HandleFunc("/login", controllers.LoginHandler)
-	mux.HandleFunc("/gallery", controllers.GalleryHandler)
-	mux.HandleFunc("/camera", internal.RequireAuth(controllers.CameraHandler))
-	mux.HandleFunc("/comments/add", internal.RequireAuth(controllers.AddComment))
-	mux.HandleFunc("/like", internal.RequireAuth(controllers.LikeImageHandler))
-	mux.HandleFunc("/password/reset", controllers.ResetPasswordHandler)
-	mux.HandleFunc("/password/change", controllers.ChangePasswordHandler)
-	mux.HandleFunc("/confirm", controllers.ConfirmAccountHandler)
-	mux.HandleFunc("/confirm_account", func(w http.ResponseWriter, r *http.Request) {
-		tmpl, err := template.ParseFiles("templates/confirm_account.html")
-		if err != nil {
-			http.Error(w, "Unable to load confirmation page", http.StatusInternalServerError)
-			return
+	app := controllers.New(stores, files, mail.NewService(outbox, baseURL), ratelimit.NewAuth(ratelimit.NewMemoryStore()), providers)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	outboxDone := make(chan struct{})
+	go func() {
+		outbox.Run(ctx)
+		close(outboxDone)
+	}()
+	go internal.Store.Run(ctx)
+
+	server := &http.Server{Addr: port, Handler: newRouter(app, stores, files)}
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutting down server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down server: %v", err)
 		}
-		tmpl.Execute(w, nil)
-	})
-	mux.HandleFunc("/logout", internal.RequireAuth(controllers.LogoutHandler))
-	mux.HandleFunc("/images/delete", internal.RequireAuth(controllers.DeleteImageHandler))
-	mux.HandleFunc("/settings", internal.RequireAuth(controllers.SettingsHandler))
+	}()
 
 	log.Printf("Starting server on %s", port)
-	if err := http.ListenAndServe(port, wrappedMux); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+
+	<-outboxDone
 }
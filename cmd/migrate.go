@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/migrations"
+)
+
+func runMigrate(databaseURL string, args []string) {
+	if len(args) != 1 || (args[0] != "status" && args[0] != "up") {
+		fmt.Fprintln(os.Stderr, "usage: photo-booth migrate status|up")
+		os.Exit(2)
+	}
+
+	db, d := internal.OpenDB(databaseURL)
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrations.GetStatus(db, d)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	case "up":
+		applied, err := migrations.Up(db, d)
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"text/template"
+
+	"photo-booth.com/controllers"
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/storage"
+	"photo-booth.com/internal/store"
+)
+
+// router is the part of http.ServeMux that registerRoutes uses.
+type router interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// newRouter returns the application's handler with every route and
+// middleware in place.
+func newRouter(app *controllers.Controller, stores store.Stores, files storage.Storage) http.Handler {
+	mux := http.NewServeMux()
+	registerRoutes(mux, app)
+
+	// Static files and uploads are served without looking at the session.
+	root := http.NewServeMux()
+	sfs := http.FileServer(http.Dir("./static"))
+	root.Handle("/static/", http.StripPrefix("/static/", sfs))
+	root.Handle("/uploads/", http.StripPrefix("/uploads/", storage.Handler(files)))
+	root.Handle("/", internal.LimitBody(internal.MaxRequestSize, internal.AuthMiddleware(stores.APITokens, internal.CSRFMiddleware(mux))))
+	return root
+}
+
+// registerRoutes registers the pages and the API, which all sit behind the
+// session and CSRF middleware.
+func registerRoutes(mux router, app *controllers.Controller) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		authenticated, _ := r.Context().Value(internal.AuthenticatedKey).(bool)
+
+		tmpl, err := template.ParseFiles("templates/index.html")
+		if err != nil {
+			http.Error(w, "Unable to load index page", http.StatusInternalServerError)
+			return
+		}
+
+		data := struct {
+			Authenticated bool
+		}{
+			Authenticated: authenticated,
+		}
+		if err := tmpl.Execute(w, data); err != nil {
+			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/register", app.RegisterHandler)
+	mux.HandleFunc("/login", app.LoginHandler)
+	mux.HandleFunc("/login/2fa", app.LoginTwoFactorHandler)
+	mux.HandleFunc("/auth/oidc/", app.OIDCHandler)
+	mux.HandleFunc("/gallery", internal.AllowScope(models.ScopeRead, app.GalleryHandler))
+	mux.HandleFunc("/camera", internal.RequireScope(models.ScopeUpload, app.CameraHandler))
+	mux.HandleFunc("/camera/strip", internal.RequireScope(models.ScopeUpload, app.StripHandler))
+	mux.HandleFunc("/camera/burst", internal.RequireScope(models.ScopeUpload, app.BurstHandler))
+	mux.HandleFunc("/upload", internal.RequireScope(models.ScopeUpload, app.UploadHandler))
+	mux.HandleFunc("/comments/add", internal.RequireScope(models.ScopeComment, app.AddComment))
+	mux.HandleFunc("/like", internal.RequireScope(models.ScopeComment, app.LikeImageHandler))
+	mux.HandleFunc("/password/reset", app.ResetPasswordHandler)
+	mux.HandleFunc("/password/change", app.ChangePasswordHandler)
+	mux.HandleFunc("/confirm", app.ConfirmAccountHandler)
+	mux.HandleFunc("/confirm_account", func(w http.ResponseWriter, r *http.Request) {
+		tmpl, err := template.ParseFiles("templates/confirm_account.html")
+		if err != nil {
+			http.Error(w, "Unable to load confirmation page", http.StatusInternalServerError)
+			return
+		}
+		authenticated, _ := r.Context().Value(internal.AuthenticatedKey).(bool)
+		tmpl.Execute(w, struct {
+			Authenticated bool
+		}{Authenticated: authenticated})
+	})
+	mux.HandleFunc("/logout", internal.RequireAuth(app.LogoutHandler))
+	mux.HandleFunc("/images/delete", internal.RequireScope(models.ScopeUpload, app.DeleteImageHandler))
+	mux.HandleFunc("/images/edit", internal.RequireScope(models.ScopeUpload, app.EditImageHandler))
+	mux.HandleFunc("/images/revert", internal.RequireScope(models.ScopeUpload, app.RevertImageHandler))
+	mux.HandleFunc("/images/original", internal.RequireScope(models.ScopeRead, app.OriginalHandler))
+	mux.HandleFunc("/images/frame", internal.RequireScope(models.ScopeRead, app.FrameHandler))
+	mux.HandleFunc("/settings", internal.RequireAuth(app.SettingsHandler))
+	mux.HandleFunc("/settings/sessions/revoke", internal.RequireAuth(app.RevokeSessionHandler))
+	mux.HandleFunc("/settings/2fa", internal.RequireAuth(app.TwoFactorSetupHandler))
+	mux.HandleFunc("/settings/2fa/disable", internal.RequireAuth(app.DisableTwoFactorHandler))
+	mux.HandleFunc("/settings/identities/unlink", internal.RequireAuth(app.UnlinkIdentityHandler))
+	mux.HandleFunc("/settings/tokens", internal.RequireAuth(app.CreateAPITokenHandler))
+	mux.HandleFunc("/settings/tokens/revoke", internal.RequireAuth(app.RevokeAPITokenHandler))
+	mux.HandleFunc("/admin/overlays", internal.RequireAuth(app.RequireAdmin(app.AdminOverlaysHandler)))
+	mux.HandleFunc("/admin/overlays/toggle", internal.RequireAuth(app.RequireAdmin(app.ToggleOverlayHandler)))
+	mux.HandleFunc("/admin/overlays/move", internal.RequireAuth(app.RequireAdmin(app.MoveOverlayHandler)))
+	mux.HandleFunc(internal.APIPrefix, app.APIHandler)
+}
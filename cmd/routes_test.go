@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"photo-booth.com/controllers"
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/mail"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/ratelimit"
+	"photo-booth.com/internal/storage"
+	"photo-booth.com/internal/store"
+)
+
+// The handlers parse their templates relative to the repository root.
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+var csrfField = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+type testApp struct {
+	app     *controllers.Controller
+	files   storage.Storage
+	handler http.Handler
+	stores  store.Stores
+	user    *models.User
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	files, err := storage.NewLocal(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := store.NewMemory()
+	internal.Store = internal.NewSessionStore(stores.Sessions, []byte("test-secret"))
+
+	mailService := mail.NewService(&mail.CaptureMailer{}, "http://localhost")
+	app := controllers.New(stores, files, mailService, ratelimit.NewAuth(ratelimit.NewMemoryStore()), nil)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "x", IsConfirmed: true}
+	if err := stores.Users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return &testApp{app: app, files: files, handler: newRouter(app, stores, files), stores: stores, user: user}
+}
+
+// signIn stores a signed-in session for the user and returns its cookie and
+// the CSRF token the settings page hands out for it.
+func (a *testApp) signIn(t *testing.T) (*http.Cookie, string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	session, _ := internal.Store.Get(r, "session")
+	session.Values["authenticated"] = true
+	session.Values["user_id"] = a.user.ID
+	if err := session.Save(r, w); err != nil {
+		t.Fatal(err)
+	}
+	cookie := w.Result().Cookies()[0]
+
+	r = httptest.NewRequest(http.MethodGet, "/settings", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	a.handler.ServeHTTP(w, r)
+	match := csrfField.FindStringSubmatch(w.Body.String())
+	if w.Code != http.StatusOK || match == nil {
+		t.Fatalf("settings page: status %d, no CSRF token: %s", w.Code, w.Body)
+	}
+	for _, updated := range w.Result().Cookies() {
+		cookie = updated
+	}
+	return cookie, match[1]
+}
+
+func (a *testApp) apiToken(t *testing.T) string {
+	t.Helper()
+	raw, hash := internal.NewAPIToken()
+	token := &models.APIToken{UserID: a.user.ID, Name: "test", TokenHash: hash, Scopes: models.Scopes}
+	if err := a.stores.APITokens.Create(token); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// routeRecorder collects the patterns registerRoutes registers.
+type routeRecorder struct {
+	patterns []string
+}
+
+func (r *routeRecorder) Handle(pattern string, handler http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+func (r *routeRecorder) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+// csrfRejected reports whether CSRFMiddleware turned the request away, as
+// opposed to the handler behind it answering.
+func csrfRejected(w *httptest.ResponseRecorder) bool {
+	return w.Code == http.StatusForbidden && strings.Contains(w.Body.String(), "CSRF token")
+}
+
+func TestCSRFMiddlewareRoutes(t *testing.T) {
+	routes := &routeRecorder{}
+	setup := newTestApp(t)
+	registerRoutes(routes, setup.app)
+
+	tests := []struct {
+		name     string
+		request  func(t *testing.T, a *testApp, route string) *http.Request
+		rejected bool
+	}{
+		{
+			name: "missing token",
+			request: func(t *testing.T, a *testApp, route string) *http.Request {
+				cookie, _ := a.signIn(t)
+				return post(route, nil, cookie, "")
+			},
+			rejected: true,
+		},
+		{
+			name: "wrong token",
+			request: func(t *testing.T, a *testApp, route string) *http.Request {
+				cookie, _ := a.signIn(t)
+				return post(route, nil, cookie, "wrong")
+			},
+			rejected: true,
+		},
+		{
+			name: "token of another session",
+			request: func(t *testing.T, a *testApp, route string) *http.Request {
+				cookie, _ := a.signIn(t)
+				_, other := a.signIn(t)
+				return post(route, nil, cookie, other)
+			},
+			rejected: true,
+		},
+		{
+			name: "token without a session",
+			request: func(t *testing.T, a *testApp, route string) *http.Request {
+				_, token := a.signIn(t)
+				return post(route, nil, nil, token)
+			},
+			rejected: true,
+		},
+		{
+			name: "valid header",
+			request: func(t *testing.T, a *testApp, route string) *http.Request {
+				cookie, token := a.signIn(t)
+				return post(route, nil, cookie, token)
+			},
+		},
+		{
+			name: "valid form field",
+			request: func(t *testing.T, a *testApp, route string) *http.Request {
+				cookie, token := a.signIn(t)
+				return post(route, url.Values{internal.CSRFFieldName: {token}}, cookie, "")
+			},
+		},
+		{
+			name: "bearer token",
+			request: func(t *testing.T, a *testApp, route string) *http.Request {
+				r := post(route, nil, nil, "")
+				r.Header.Set("Authorization", "Bearer "+a.apiToken(t))
+				return r
+			},
+		},
+	}
+
+	for _, route := range routes.patterns {
+		for _, tt := range tests {
+			t.Run(route+"/"+tt.name, func(t *testing.T) {
+				a := newTestApp(t)
+				w := httptest.NewRecorder()
+				a.handler.ServeHTTP(w, tt.request(t, a, route))
+				if got := csrfRejected(w); got != tt.rejected {
+					t.Errorf("rejected = %v, want %v (status %d: %s)", got, tt.rejected, w.Code, strings.TrimSpace(w.Body.String()))
+				}
+			})
+		}
+	}
+}
+
+// post builds a form POST to path with an optional session cookie and
+// X-CSRF-Token header.
+func post(path string, form url.Values, cookie *http.Cookie, csrfHeader string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	if csrfHeader != "" {
+		r.Header.Set(internal.CSRFHeaderName, csrfHeader)
+	}
+	return r
+}
+
+func TestCSRFMiddlewareSafeMethods(t *testing.T) {
+	a := newTestApp(t)
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w := httptest.NewRecorder()
+		a.handler.ServeHTTP(w, httptest.NewRequest(method, "/gallery", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s /gallery without a token: status %d", method, w.Code)
+		}
+	}
+}
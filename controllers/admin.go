@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
+)
+
+var errOverlayNameRequired = errors.New("overlay name is required")
+
+// RequireAdmin lets only admins through. It goes inside RequireAuth.
+func (c *Controller) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(internal.UserIDKey).(int)
+		user, err := c.Users.GetByID(userID)
+		if err != nil || !user.IsAdmin {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// AdminOverlaysHandler lists the whole overlay catalog and adds overlays
+// uploaded as the "file" field of a multipart form.
+func (c *Controller) AdminOverlaysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if _, err := c.createOverlay(r); err != nil {
+			status, message := overlayError(err)
+			http.Error(w, message, status)
+			return
+		}
+		http.Redirect(w, r, "/admin/overlays", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	overlays, err := c.Overlays.List(true)
+	if err != nil {
+		http.Error(w, "Unable to load overlays", http.StatusInternalServerError)
+		return
+	}
+	for i := range overlays {
+		overlays[i].URL = c.Files.URL(overlays[i].FilePath)
+	}
+
+	tmpl, err := template.ParseFiles("templates/admin_overlays.html")
+	if err != nil {
+		http.Error(w, "Unable to load overlay admin page", http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, struct {
+		Overlays      []models.Overlay
+		Authenticated bool
+		CSRFToken     string
+	}{Overlays: overlays, Authenticated: true, CSRFToken: internal.CSRFToken(r)})
+}
+
+// ToggleOverlayHandler enables or disables an overlay. Disabled overlays stay
+// in the catalog but can't be picked for new photos.
+func (c *Controller) ToggleOverlayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := c.Overlays.SetEnabled(r.FormValue("slug"), r.FormValue("enabled") == "true")
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Overlay not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update overlay", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/overlays", http.StatusSeeOther)
+}
+
+// MoveOverlayHandler moves an overlay one place up or down the catalog.
+func (c *Controller) MoveOverlayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	overlays, err := c.Overlays.List(true)
+	if err != nil {
+		http.Error(w, "Unable to load overlays", http.StatusInternalServerError)
+		return
+	}
+
+	slug := r.FormValue("slug")
+	slugs := make([]string, len(overlays))
+	index := -1
+	for i, overlay := range overlays {
+		slugs[i] = overlay.Slug
+		if overlay.Slug == slug {
+			index = i
+		}
+	}
+	if index < 0 {
+		http.Error(w, "Overlay not found", http.StatusNotFound)
+		return
+	}
+
+	other := index + 1
+	if r.FormValue("direction") == "up" {
+		other = index - 1
+	}
+	if other >= 0 && other < len(slugs) {
+		slugs[index], slugs[other] = slugs[other], slugs[index]
+		if err := c.Overlays.Reorder(slugs); err != nil {
+			http.Error(w, "Failed to reorder overlays", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/admin/overlays", http.StatusSeeOther)
+}
+
+// createOverlay adds the overlay uploaded with a multipart form. The slug is
+// derived from the name unless one is given.
+func (c *Controller) createOverlay(r *http.Request) (*models.Overlay, error) {
+	upload, err := readUpload(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := imaging.ValidateOverlay(upload); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		return nil, errOverlayNameRequired
+	}
+	slug := internal.Slugify(r.FormValue("slug"))
+	if slug == "" {
+		slug = internal.Slugify(name)
+	}
+
+	overlay := &models.Overlay{
+		Slug:     slug,
+		Name:     name,
+		Category: strings.TrimSpace(r.FormValue("category")),
+		Enabled:  true,
+	}
+	if err := internal.SaveOverlay(c.Overlays, c.Files, overlay, upload.Image); err != nil {
+		return nil, err
+	}
+	overlay.URL = c.Files.URL(overlay.FilePath)
+	return overlay, nil
+}
+
+// overlayError maps an error from createOverlay to a status code and message.
+func overlayError(err error) (int, string) {
+	switch {
+	case errors.Is(err, errOverlayNameRequired):
+		return http.StatusBadRequest, "Overlay name is required"
+	case errors.Is(err, store.ErrDuplicate):
+		return http.StatusConflict, "An overlay with this slug already exists"
+	case errors.Is(err, imaging.ErrOverlayNotPNG):
+		return http.StatusUnsupportedMediaType, "Overlays must be PNG images"
+	case errors.Is(err, imaging.ErrOverlayOpaque):
+		return http.StatusBadRequest, "Overlays need transparent pixels for the photo to show through"
+	case errors.Is(err, errNoImageData):
+		return http.StatusBadRequest, "No overlay file provided"
+	case errors.Is(err, errUploadTooLarge), errors.Is(err, imaging.ErrUnsupportedFormat),
+		errors.Is(err, imaging.ErrImageTooLarge), errors.Is(err, imaging.ErrInvalidImage):
+		return imageError(err)
+	}
+	log.Printf("Error saving overlay: %v", err)
+	return http.StatusInternalServerError, "Unable to save overlay"
+}
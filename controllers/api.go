@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"photo-booth.com/internal"
+)
+
+// maxAPIBody caps JSON request bodies; captures are sent inline as data URLs.
+const maxAPIBody = 10 << 20
+
+// APIHandler serves the JSON API under /api/v1/. Reads are public like the
+// gallery, writes need a signed-in session or an API token with the right
+// scope. Session requests must send the X-CSRF-Token header.
+func (c *Controller) APIHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, internal.APIPrefix), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "openapi.yaml":
+		c.apiOpenAPI(w, r)
+	case len(parts) == 1 && parts[0] == "images":
+		c.apiImages(w, r)
+	case len(parts) == 2 && parts[0] == "images":
+		c.apiImage(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "images" && parts[2] == "comments":
+		c.apiImageComments(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "images" && parts[2] == "likes":
+		c.apiImageLikes(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "images" && parts[2] == "recipe":
+		c.apiImageRecipe(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "images" && parts[2] == "revert":
+		c.apiImageRevert(w, r, parts[1])
+	case len(parts) == 1 && parts[0] == "strips":
+		c.apiStrips(w, r)
+	case len(parts) == 1 && parts[0] == "animations":
+		c.apiAnimations(w, r)
+	case len(parts) == 2 && parts[0] == "comments":
+		c.apiComment(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "users":
+		c.apiUser(w, r, parts[1])
+	case len(parts) == 1 && parts[0] == "overlays":
+		c.apiOverlays(w, r)
+	case len(parts) == 2 && parts[0] == "overlays":
+		c.apiOverlay(w, r, parts[1])
+	case len(parts) == 1 && parts[0] == "stickers":
+		c.apiStickers(w, r)
+	default:
+		internal.WriteAPIError(w, http.StatusNotFound, "No such endpoint")
+	}
+}
+
+func (c *Controller) apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	http.ServeFile(w, r, "static/openapi.yaml")
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	internal.WriteAPIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+// apiAllow checks a public endpoint: anonymous visitors and sessions pass, API
+// tokens need scope.
+func apiAllow(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if token := internal.APIToken(r); token != nil && !token.HasScope(scope) {
+		internal.WriteAPIError(w, http.StatusForbidden, "API token is missing the "+scope+" scope")
+		return false
+	}
+	return true
+}
+
+// apiUserID returns the ID of the signed-in user, or writes an error if there
+// is none or the API token lacks scope.
+func apiUserID(w http.ResponseWriter, r *http.Request, scope string) (int, bool) {
+	authenticated, _ := r.Context().Value(internal.AuthenticatedKey).(bool)
+	userID, _ := r.Context().Value(internal.UserIDKey).(int)
+	if !authenticated || userID == 0 {
+		internal.WriteAPIError(w, http.StatusUnauthorized, "Authentication required")
+		return 0, false
+	}
+	if !apiAllow(w, r, scope) {
+		return 0, false
+	}
+	return userID, true
+}
+
+func parseID(w http.ResponseWriter, value, name string) (int, bool) {
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		internal.WriteAPIError(w, http.StatusNotFound, name+" not found")
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeJSON reads a JSON request body into v, rejecting unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		internal.WriteAPIError(w, http.StatusRequestEntityTooLarge, "Request body is too large")
+	case errors.Is(err, io.EOF):
+		internal.WriteAPIError(w, http.StatusBadRequest, "Request body is empty")
+	default:
+		internal.WriteAPIError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"time"
+
+	"photo-booth.com/internal/models"
+)
+
+// The API responds with these DTOs rather than the models, so storage details
+// like file paths and password hashes never leave the server.
+
+type userDTO struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// meDTO is the signed-in user's own profile.
+type meDTO struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type renditionDTO struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Width int    `json:"width"`
+}
+
+type frameDTO struct {
+	Position int    `json:"position"`
+	URL      string `json:"url"`
+}
+
+type commentDTO struct {
+	ID        int       `json:"id"`
+	ImageID   int       `json:"image_id"`
+	Author    userDTO   `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type imageDTO struct {
+	ID           int            `json:"id"`
+	Author       userDTO        `json:"author"`
+	URL          string         `json:"url"`
+	ThumbnailURL string         `json:"thumbnail_url"`
+	Srcset       string         `json:"srcset"`
+	Renditions   []renditionDTO `json:"renditions"`
+	Likes        int            `json:"likes"`
+	Comments     []commentDTO   `json:"comments"`
+	IsOwner      bool           `json:"is_owner"`
+	CreatedAt    time.Time      `json:"created_at"`
+	// CapturedAt is the camera's local time, which EXIF records without a
+	// time zone; it is sent as if it were UTC.
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+	// Overlay and Edits are the recipe the image was rendered with.
+	Overlay string        `json:"overlay,omitempty"`
+	Edits   *models.Edits `json:"edits,omitempty"`
+	// Layout is only set for photo strips and animations. Frames are only
+	// sent to the owner of a strip.
+	Layout string     `json:"layout,omitempty"`
+	Frames []frameDTO `json:"frames,omitempty"`
+	// OriginalURL is only sent to the owner, and only for images that can be
+	// edited.
+	OriginalURL string `json:"original_url,omitempty"`
+}
+
+type imagePageDTO struct {
+	Images     []imageDTO `json:"images"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+type overlayDTO struct {
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	URL       string `json:"url"`
+	Enabled   bool   `json:"enabled"`
+	SortOrder int    `json:"sort_order"`
+}
+
+type stickerDTO struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// newImageDTO expects the image URLs to be resolved already.
+func newImageDTO(image models.Image) imageDTO {
+	dto := imageDTO{
+		ID:           image.ID,
+		Author:       userDTO{ID: image.UserID, Username: image.Username},
+		URL:          image.URL,
+		ThumbnailURL: image.ThumbnailURL,
+		Srcset:       image.Srcset,
+		Renditions:   []renditionDTO{},
+		Likes:        image.Likes,
+		Comments:     []commentDTO{},
+		IsOwner:      image.IsOwner,
+		CreatedAt:    image.CreatedAt,
+		Overlay:      image.Overlay,
+		Layout:       image.Layout,
+	}
+	if image.IsOwner {
+		dto.OriginalURL = image.OriginalURL
+		for _, frame := range image.Frames {
+			dto.Frames = append(dto.Frames, frameDTO{Position: frame.Position, URL: frame.URL})
+		}
+	}
+	if !image.CapturedAt.IsZero() {
+		dto.CapturedAt = &image.CapturedAt
+	}
+	if !image.Edits.IsZero() {
+		dto.Edits = &image.Edits
+	}
+	for _, rendition := range image.Renditions {
+		dto.Renditions = append(dto.Renditions, renditionDTO{Name: rendition.Name, URL: rendition.URL, Width: rendition.Width})
+	}
+	for _, comment := range image.Comments {
+		dto.Comments = append(dto.Comments, newCommentDTO(comment))
+	}
+	return dto
+}
+
+func newCommentDTO(comment models.Comment) commentDTO {
+	return commentDTO{
+		ID:        comment.ID,
+		ImageID:   comment.ImageID,
+		Author:    userDTO{ID: comment.UserID, Username: comment.Username},
+		Content:   comment.Content,
+		CreatedAt: comment.CreatedAt,
+	}
+}
+
+// newOverlayDTO expects the overlay URL to be resolved already.
+func newOverlayDTO(overlay models.Overlay) overlayDTO {
+	return overlayDTO{
+		Slug:      overlay.Slug,
+		Name:      overlay.Name,
+		Category:  overlay.Category,
+		URL:       overlay.URL,
+		Enabled:   overlay.Enabled,
+		SortOrder: overlay.SortOrder,
+	}
+}
@@ -0,0 +1,491 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// apiImages serves GET and POST /api/v1/images.
+func (c *Controller) apiImages(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !apiAllow(w, r, models.ScopeRead) {
+			return
+		}
+		c.apiListImages(w, r)
+	case http.MethodPost:
+		userID, ok := apiUserID(w, r, models.ScopeUpload)
+		if !ok {
+			return
+		}
+		c.apiCreateImage(w, r, userID)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (c *Controller) apiListImages(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(internal.UserIDKey).(int)
+	query := r.URL.Query()
+
+	var after store.Cursor
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := store.DecodeCursor(token)
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		after = cursor
+	}
+
+	limit := defaultPageSize
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			internal.WriteAPIError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	images, err := c.Images.ListPage(userID, after, limit+1)
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to retrieve images")
+		return
+	}
+
+	page := imagePageDTO{Images: []imageDTO{}}
+	if len(images) > limit {
+		images = images[:limit]
+		page.NextCursor = store.CursorFor(images[limit-1]).Encode()
+	}
+	for _, image := range images {
+		image.ResolveURLs(c.Files.URL)
+		page.Images = append(page.Images, newImageDTO(image))
+	}
+
+	internal.WriteJSON(w, http.StatusOK, page)
+}
+
+// apiCreateImage accepts either a multipart upload like /upload or a JSON
+// capture like the camera page sends.
+func (c *Controller) apiCreateImage(w http.ResponseWriter, r *http.Request, userID int) {
+	var imageID int
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		imageID, err = c.saveUpload(r, userID)
+	} else {
+		var body struct {
+			Image   string       `json:"image"`
+			Overlay string       `json:"overlay"`
+			Edits   models.Edits `json:"edits"`
+		}
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		imageID, err = c.saveCapture(userID, body.Image, body.Overlay, body.Edits)
+	}
+	if err != nil {
+		status, message := imageError(err)
+		internal.WriteAPIError(w, status, message)
+		return
+	}
+
+	c.writeImage(w, http.StatusCreated, userID, imageID)
+}
+
+// apiStrips serves POST /api/v1/strips, which saves a photo strip like the
+// camera page's strip mode. Layout fields that are left out keep their
+// defaults.
+func (c *Controller) apiStrips(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	userID, ok := apiUserID(w, r, models.ScopeUpload)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Frames     []string     `json:"frames"`
+		Overlay    string       `json:"overlay"`
+		Edits      models.Edits `json:"edits"`
+		Layout     string       `json:"layout"`
+		Border     *int         `json:"border"`
+		Spacing    *int         `json:"spacing"`
+		Background string       `json:"background"`
+		Footer     string       `json:"footer"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	options := defaultStripOptions()
+	if body.Layout != "" {
+		options.Layout = body.Layout
+	}
+	if body.Border != nil {
+		options.Border = *body.Border
+	}
+	if body.Spacing != nil {
+		options.Spacing = *body.Spacing
+	}
+	options.Footer = strings.TrimSpace(body.Footer)
+
+	var imageID int
+	var err error
+	if body.Background != "" {
+		options.Background, err = imaging.ParseColor(body.Background)
+	}
+	if err == nil {
+		imageID, err = c.saveStrip(userID, body.Frames, body.Overlay, body.Edits, options)
+	}
+	if err != nil {
+		status, message := imageError(err)
+		internal.WriteAPIError(w, status, message)
+		return
+	}
+
+	c.writeImage(w, http.StatusCreated, userID, imageID)
+}
+
+// apiAnimations serves POST /api/v1/animations, which saves burst frames as
+// an animated GIF like the camera page's burst mode.
+func (c *Controller) apiAnimations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	userID, ok := apiUserID(w, r, models.ScopeUpload)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Frames    []string     `json:"frames"`
+		Overlay   string       `json:"overlay"`
+		Edits     models.Edits `json:"edits"`
+		Delay     *int         `json:"delay"`
+		Boomerang bool         `json:"boomerang"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	options := imaging.AnimationOptions{Delay: imaging.DefaultFrameDelay, Boomerang: body.Boomerang}
+	if body.Delay != nil {
+		options.Delay = *body.Delay
+	}
+	imageID, err := c.saveAnimation(userID, body.Frames, body.Overlay, body.Edits, options)
+	if err != nil {
+		status, message := imageError(err)
+		internal.WriteAPIError(w, status, message)
+		return
+	}
+
+	c.writeImage(w, http.StatusCreated, userID, imageID)
+}
+
+// apiImage serves GET and DELETE /api/v1/images/{id}.
+func (c *Controller) apiImage(w http.ResponseWriter, r *http.Request, id string) {
+	imageID, ok := parseID(w, id, "Image")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !apiAllow(w, r, models.ScopeRead) {
+			return
+		}
+		userID, _ := r.Context().Value(internal.UserIDKey).(int)
+		c.writeImage(w, http.StatusOK, userID, imageID)
+	case http.MethodDelete:
+		userID, ok := apiUserID(w, r, models.ScopeUpload)
+		if !ok {
+			return
+		}
+		image, ok := c.apiFindImage(w, imageID)
+		if !ok {
+			return
+		}
+		if image.UserID != userID {
+			internal.WriteAPIError(w, http.StatusForbidden, "You are not authorized to delete this image")
+			return
+		}
+		if err := c.deleteImage(image); err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Failed to delete image")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	}
+}
+
+func (c *Controller) writeImage(w http.ResponseWriter, status, viewerID, imageID int) {
+	image, err := c.Images.Get(viewerID, imageID)
+	if errors.Is(err, store.ErrNotFound) {
+		internal.WriteAPIError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to retrieve image")
+		return
+	}
+	image.ResolveURLs(c.Files.URL)
+	internal.WriteJSON(w, status, newImageDTO(*image))
+}
+
+func (c *Controller) apiFindImage(w http.ResponseWriter, imageID int) (*models.Image, bool) {
+	image, err := c.Images.GetByID(imageID)
+	if errors.Is(err, store.ErrNotFound) {
+		internal.WriteAPIError(w, http.StatusNotFound, "Image not found")
+		return nil, false
+	}
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to retrieve image")
+		return nil, false
+	}
+	return image, true
+}
+
+// apiImageComments serves GET and POST /api/v1/images/{id}/comments.
+func (c *Controller) apiImageComments(w http.ResponseWriter, r *http.Request, id string) {
+	imageID, ok := parseID(w, id, "Image")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !apiAllow(w, r, models.ScopeRead) {
+			return
+		}
+		if _, ok := c.apiFindImage(w, imageID); !ok {
+			return
+		}
+		comments, err := c.Comments.ListByImage(imageID)
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to retrieve comments")
+			return
+		}
+		dtos := []commentDTO{}
+		for _, comment := range comments {
+			dtos = append(dtos, newCommentDTO(comment))
+		}
+		internal.WriteJSON(w, http.StatusOK, struct {
+			Comments []commentDTO `json:"comments"`
+		}{dtos})
+	case http.MethodPost:
+		userID, ok := apiUserID(w, r, models.ScopeComment)
+		if !ok {
+			return
+		}
+		var body struct {
+			Content string `json:"content"`
+		}
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if strings.TrimSpace(body.Content) == "" {
+			internal.WriteAPIError(w, http.StatusBadRequest, "content is required")
+			return
+		}
+		if _, ok := c.apiFindImage(w, imageID); !ok {
+			return
+		}
+
+		commentID, err := c.addComment(imageID, userID, body.Content)
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Failed to add comment")
+			return
+		}
+		comment, err := c.Comments.Get(commentID)
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to retrieve comment")
+			return
+		}
+		internal.WriteJSON(w, http.StatusCreated, newCommentDTO(*comment))
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// apiComment serves GET and DELETE /api/v1/comments/{id}. A comment can be
+// deleted by its author and by the owner of the image.
+func (c *Controller) apiComment(w http.ResponseWriter, r *http.Request, id string) {
+	commentID, ok := parseID(w, id, "Comment")
+	if !ok {
+		return
+	}
+
+	var userID int
+	switch r.Method {
+	case http.MethodGet:
+		if !apiAllow(w, r, models.ScopeRead) {
+			return
+		}
+	case http.MethodDelete:
+		if userID, ok = apiUserID(w, r, models.ScopeComment); !ok {
+			return
+		}
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		return
+	}
+
+	comment, err := c.Comments.Get(commentID)
+	if errors.Is(err, store.ErrNotFound) {
+		internal.WriteAPIError(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to retrieve comment")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		internal.WriteJSON(w, http.StatusOK, newCommentDTO(*comment))
+		return
+	}
+
+	if comment.UserID != userID {
+		image, ok := c.apiFindImage(w, comment.ImageID)
+		if !ok {
+			return
+		}
+		if image.UserID != userID {
+			internal.WriteAPIError(w, http.StatusForbidden, "You are not authorized to delete this comment")
+			return
+		}
+	}
+
+	if err := c.Comments.Delete(commentID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Failed to delete comment")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiImageLikes serves POST and DELETE /api/v1/images/{id}/likes, which like
+// and unlike the image as the signed-in user.
+func (c *Controller) apiImageLikes(w http.ResponseWriter, r *http.Request, id string) {
+	imageID, ok := parseID(w, id, "Image")
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		methodNotAllowed(w, http.MethodPost, http.MethodDelete)
+		return
+	}
+
+	userID, ok := apiUserID(w, r, models.ScopeComment)
+	if !ok {
+		return
+	}
+	if _, ok := c.apiFindImage(w, imageID); !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		err := c.Likes.Add(userID, imageID)
+		if errors.Is(err, store.ErrLikeExists) {
+			internal.WriteAPIError(w, http.StatusConflict, "You have already liked this image")
+			return
+		}
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to like image")
+			return
+		}
+		c.writeImage(w, http.StatusCreated, userID, imageID)
+		return
+	}
+
+	err := c.Likes.Remove(userID, imageID)
+	if errors.Is(err, store.ErrNotFound) {
+		internal.WriteAPIError(w, http.StatusNotFound, "You have not liked this image")
+		return
+	}
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to unlike image")
+		return
+	}
+	c.writeImage(w, http.StatusOK, userID, imageID)
+}
+
+// apiImageRecipe serves PUT /api/v1/images/{id}/recipe, which re-renders one
+// of the user's images from its original with a new overlay and edits.
+func (c *Controller) apiImageRecipe(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, http.MethodPut)
+		return
+	}
+
+	var body struct {
+		Overlay string       `json:"overlay"`
+		Edits   models.Edits `json:"edits"`
+	}
+	image, ok := c.apiOwnImage(w, r, id)
+	if !ok || !decodeJSON(w, r, &body) {
+		return
+	}
+	c.apiRerender(w, image, body.Overlay, body.Edits)
+}
+
+// apiImageRevert serves POST /api/v1/images/{id}/revert, which renders one of
+// the user's images from its original without overlay or edits.
+func (c *Controller) apiImageRevert(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	image, ok := c.apiOwnImage(w, r, id)
+	if !ok {
+		return
+	}
+	c.apiRerender(w, image, "", models.Edits{})
+}
+
+func (c *Controller) apiOwnImage(w http.ResponseWriter, r *http.Request, id string) (*models.Image, bool) {
+	imageID, ok := parseID(w, id, "Image")
+	if !ok {
+		return nil, false
+	}
+	userID, ok := apiUserID(w, r, models.ScopeUpload)
+	if !ok {
+		return nil, false
+	}
+	image, ok := c.apiFindImage(w, imageID)
+	if !ok {
+		return nil, false
+	}
+	if image.UserID != userID {
+		internal.WriteAPIError(w, http.StatusForbidden, "You are not authorized to edit this image")
+		return nil, false
+	}
+	return image, true
+}
+
+func (c *Controller) apiRerender(w http.ResponseWriter, image *models.Image, overlayName string, edits models.Edits) {
+	if err := c.rerender(image, overlayName, edits); err != nil {
+		status, message := imageError(err)
+		internal.WriteAPIError(w, status, message)
+		return
+	}
+	c.writeImage(w, http.StatusOK, image.UserID, image.ID)
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
+)
+
+// apiOverlays serves GET, POST and PUT /api/v1/overlays. Everyone can list
+// the enabled overlays; admins can list all of them with ?all=true, add
+// overlays and reorder the catalog.
+func (c *Controller) apiOverlays(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		includeDisabled := r.URL.Query().Get("all") == "true"
+		if includeDisabled {
+			if _, ok := c.apiAdminID(w, r); !ok {
+				return
+			}
+		} else if !apiAllow(w, r, models.ScopeRead) {
+			return
+		}
+		c.writeOverlays(w, includeDisabled)
+	case http.MethodPost:
+		if _, ok := c.apiAdminID(w, r); !ok {
+			return
+		}
+		overlay, err := c.createOverlay(r)
+		if err != nil {
+			status, message := overlayError(err)
+			internal.WriteAPIError(w, status, message)
+			return
+		}
+		internal.WriteJSON(w, http.StatusCreated, newOverlayDTO(*overlay))
+	case http.MethodPut:
+		if _, ok := c.apiAdminID(w, r); !ok {
+			return
+		}
+		var body struct {
+			Order []string `json:"order"`
+		}
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if len(body.Order) == 0 {
+			internal.WriteAPIError(w, http.StatusBadRequest, "order is required")
+			return
+		}
+		err := c.Overlays.Reorder(body.Order)
+		if errors.Is(err, store.ErrNotFound) {
+			internal.WriteAPIError(w, http.StatusBadRequest, "order contains an unknown overlay")
+			return
+		}
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Failed to reorder overlays")
+			return
+		}
+		c.writeOverlays(w, true)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodPut)
+	}
+}
+
+func (c *Controller) writeOverlays(w http.ResponseWriter, includeDisabled bool) {
+	overlays, err := c.Overlays.List(includeDisabled)
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to load overlays")
+		return
+	}
+
+	dtos := []overlayDTO{}
+	for _, overlay := range overlays {
+		overlay.URL = c.Files.URL(overlay.FilePath)
+		dtos = append(dtos, newOverlayDTO(overlay))
+	}
+	internal.WriteJSON(w, http.StatusOK, struct {
+		Overlays []overlayDTO `json:"overlays"`
+	}{dtos})
+}
+
+// apiOverlay serves GET and PATCH /api/v1/overlays/{slug}. Disabled overlays
+// are only visible to admins, who can also enable and disable overlays.
+func (c *Controller) apiOverlay(w http.ResponseWriter, r *http.Request, slug string) {
+	switch r.Method {
+	case http.MethodGet:
+		if !apiAllow(w, r, models.ScopeRead) {
+			return
+		}
+	case http.MethodPatch:
+		if _, ok := c.apiAdminID(w, r); !ok {
+			return
+		}
+		var body struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if !decodeJSON(w, r, &body) {
+			return
+		}
+		if body.Enabled == nil {
+			internal.WriteAPIError(w, http.StatusBadRequest, "enabled is required")
+			return
+		}
+		err := c.Overlays.SetEnabled(slug, *body.Enabled)
+		if errors.Is(err, store.ErrNotFound) {
+			internal.WriteAPIError(w, http.StatusNotFound, "Overlay not found")
+			return
+		}
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Failed to update overlay")
+			return
+		}
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPatch)
+		return
+	}
+
+	overlay, err := c.Overlays.GetBySlug(slug)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !overlay.Enabled && !c.isAdmin(r)) {
+		internal.WriteAPIError(w, http.StatusNotFound, "Overlay not found")
+		return
+	}
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to load overlay")
+		return
+	}
+	overlay.URL = c.Files.URL(overlay.FilePath)
+	internal.WriteJSON(w, http.StatusOK, newOverlayDTO(*overlay))
+}
+
+// apiStickers serves GET /api/v1/stickers, which lists the stickers and
+// fonts layers can use.
+func (c *Controller) apiStickers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	if !apiAllow(w, r, models.ScopeRead) {
+		return
+	}
+
+	names, err := internal.ListStickers()
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to load stickers")
+		return
+	}
+	dtos := []stickerDTO{}
+	for _, name := range names {
+		dtos = append(dtos, stickerDTO{Name: name, URL: internal.StickerURL(name)})
+	}
+	internal.WriteJSON(w, http.StatusOK, struct {
+		Stickers []stickerDTO `json:"stickers"`
+		Fonts    []string     `json:"fonts"`
+	}{dtos, imaging.Fonts})
+}
+
+// isAdmin reports whether the request is from an admin. API tokens also need
+// the admin scope.
+func (c *Controller) isAdmin(r *http.Request) bool {
+	authenticated, _ := r.Context().Value(internal.AuthenticatedKey).(bool)
+	userID, _ := r.Context().Value(internal.UserIDKey).(int)
+	if !authenticated || userID == 0 {
+		return false
+	}
+	if token := internal.APIToken(r); token != nil && !token.HasScope(models.ScopeAdmin) {
+		return false
+	}
+	user, err := c.Users.GetByID(userID)
+	return err == nil && user.IsAdmin
+}
+
+// apiAdminID is apiUserID for admin-only endpoints.
+func (c *Controller) apiAdminID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, ok := apiUserID(w, r, models.ScopeAdmin)
+	if !ok {
+		return 0, false
+	}
+	if !c.isAdmin(r) {
+		internal.WriteAPIError(w, http.StatusForbidden, "Admin rights required")
+		return 0, false
+	}
+	return userID, true
+}
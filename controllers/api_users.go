@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
+)
+
+// apiUser serves GET /api/v1/users/{id} and /api/v1/users/me, which also
+// includes the email address.
+func (c *Controller) apiUser(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if id == "me" {
+		userID, ok := apiUserID(w, r, models.ScopeRead)
+		if !ok {
+			return
+		}
+		user, err := c.Users.GetByID(userID)
+		if err != nil {
+			internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to load user data")
+			return
+		}
+		internal.WriteJSON(w, http.StatusOK, meDTO{ID: user.ID, Username: user.Username, Email: user.Email})
+		return
+	}
+
+	if !apiAllow(w, r, models.ScopeRead) {
+		return
+	}
+	userID, ok := parseID(w, id, "User")
+	if !ok {
+		return
+	}
+	user, err := c.Users.GetByID(userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !user.IsConfirmed) {
+		internal.WriteAPIError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		internal.WriteAPIError(w, http.StatusInternalServerError, "Unable to load user data")
+		return
+	}
+	internal.WriteJSON(w, http.StatusOK, userDTO{ID: user.ID, Username: user.Username})
+}
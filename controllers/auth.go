@@ -1,20 +1,41 @@
 package controllers
 
 import (
+	"errors"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"photo-booth.com/internal"
 	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/ratelimit"
+	"photo-booth.com/internal/sso"
+	"photo-booth.com/internal/store"
 	"photo-booth.com/internal/utils"
 )
 
-func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("photo-booth"), bcrypt.DefaultCost)
+
+func allow(w http.ResponseWriter, limiter *ratelimit.Limiter, key string) bool {
+	ok, retryAfter := limiter.Allow(key)
+	if !ok {
+		tooManyRequests(w, retryAfter)
+	}
+	return ok
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too many attempts, please try again later", http.StatusTooManyRequests)
+}
+
+func (c *Controller) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		authenticated := r.Context().Value(internal.AuthenticatedKey).(bool)
 
@@ -25,7 +46,8 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		tmpl.Execute(w, struct {
 			Authenticated bool
-		}{Authenticated: authenticated})
+			CSRFToken     string
+		}{Authenticated: authenticated, CSRFToken: internal.CSRFToken(r)})
 		return
 	}
 
@@ -35,8 +57,8 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		username := r.FormValue("username")
-		email := r.FormValue("email")
+		username := strings.TrimSpace(r.FormValue("username"))
+		email := strings.TrimSpace(r.FormValue("email"))
 		password := r.FormValue("password")
 		confirmPassword := r.FormValue("confirm_password")
 
@@ -59,25 +81,29 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 			IsConfirmed:       false,
 		}
 
-		if err := internal.CreateUser(&user); err != nil {
+		if err := c.Users.Create(&user); err != nil {
 			http.Error(w, "Error creating user", http.StatusInternalServerError)
 			return
 		}
 
-		go utils.SendConfirmationEmail(user.Email, user.ConfirmationToken)
+		if err := c.Mail.SendConfirmation(user.Email, user.ConfirmationToken); err != nil {
+			log.Printf("Error queueing confirmation email: %v", err)
+			http.Error(w, "Unable to send confirmation email", http.StatusInternalServerError)
+			return
+		}
 
 		http.Redirect(w, r, "/confirm_account", http.StatusSeeOther)
 	}
 }
 
-func ConfirmAccountHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) ConfirmAccountHandler(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
 	if token == "" {
 		http.Error(w, "Invalid token", http.StatusBadRequest)
 		return
 	}
 
-	if err := internal.ConfirmUser(token); err != nil {
+	if err := c.Users.Confirm(token); err != nil {
 		http.Error(w, "Invalid or expired token", http.StatusBadRequest)
 		return
 	}
@@ -85,7 +111,7 @@ func ConfirmAccountHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		authenticated := r.Context().Value(internal.AuthenticatedKey).(bool)
 
@@ -95,8 +121,10 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		tmpl.Execute(w, struct {
+			Providers     []*sso.Provider
 			Authenticated bool
-		}{Authenticated: authenticated})
+			CSRFToken     string
+		}{Providers: c.Providers, Authenticated: authenticated, CSRFToken: internal.CSRFToken(r)})
 		return
 	}
 
@@ -106,75 +134,144 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		username := r.FormValue("username")
+		// The username is looked up exactly as the limits are keyed, so
+		// variations of it can't reach an account without counting against it.
+		username := strings.TrimSpace(r.FormValue("username"))
 		password := r.FormValue("password")
 
-		storedUser, err := internal.GetUserByUsername(username)
-		if err != nil || bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(password)) != nil {
+		if !allow(w, c.Limits.LoginIP, internal.ClientIP(r)) || !allow(w, c.Limits.LoginAccount, username) {
+			return
+		}
+		if locked := c.Limits.Lockout.Check(username); locked > 0 {
+			tooManyRequests(w, locked)
+			return
+		}
+
+		// Unknown usernames are compared against a dummy hash so that they
+		// take as long to reject as a wrong password.
+		hash := dummyPasswordHash
+		storedUser, err := c.Users.GetByUsername(username)
+		if err == nil {
+			hash = []byte(storedUser.Password)
+		}
+		if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || err != nil {
+			c.Limits.Lockout.Fail(username)
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 			return
 		}
+		c.Limits.Lockout.Reset(username)
 
 		if !storedUser.IsConfirmed {
 			http.Error(w, "Account not confirmed", http.StatusForbidden)
 			return
 		}
 
+		c.completeLogin(w, r, storedUser.ID)
+	}
+}
+
+// completeLogin signs the user in, or sends them to the second login step if
+// they have two-factor authentication enabled.
+func (c *Controller) completeLogin(w http.ResponseWriter, r *http.Request, userID int) {
+	tf, err := c.TwoFactor.Get(userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Unable to load two-factor settings", http.StatusInternalServerError)
+		return
+	}
+	if tf != nil && tf.Enabled {
 		session, _ := internal.Store.Get(r, "session")
-		session.Values["authenticated"] = true
-		session.Values["user_id"] = storedUser.ID
+		session.Values["pending_user_id"] = userID
+		session.Values["pending_since"] = time.Now().Unix()
 		if err := session.Save(r, w); err != nil {
 			log.Printf("Error saving session: %v", err)
 			http.Error(w, "Unable to save session", http.StatusInternalServerError)
 			return
 		}
+		http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+		return
+	}
 
-		http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+	if err := signIn(w, r, userID); err != nil {
+		log.Printf("Error saving session: %v", err)
+		http.Error(w, "Unable to save session", http.StatusInternalServerError)
+		return
 	}
+
+	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
 }
 
-func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		tmpl, err := template.ParseFiles("templates/reset_password.html")
 		if err != nil {
 			http.Error(w, "Unable to load reset password page", http.StatusInternalServerError)
 			return
 		}
-		tmpl.Execute(w, nil)
+		authenticated, _ := r.Context().Value(internal.AuthenticatedKey).(bool)
+		tmpl.Execute(w, struct {
+			Authenticated bool
+			CSRFToken     string
+		}{Authenticated: authenticated, CSRFToken: internal.CSRFToken(r)})
 		return
 	} else if r.Method == http.MethodPost {
-		email := r.FormValue("email")
+		email := strings.TrimSpace(r.FormValue("email"))
 		if email == "" {
 			http.Error(w, "Email is required", http.StatusBadRequest)
 			return
 		}
 
-		user, err := internal.GetUserByEmail(email)
-		if err != nil {
-			http.Error(w, "User not found", http.StatusNotFound)
+		if !allow(w, c.Limits.ResetIP, internal.ClientIP(r)) || !allow(w, c.Limits.ResetAccount, email) {
 			return
 		}
 
-		token := utils.GenerateToken()
-		expiryStr := os.Getenv("RESET_TOKEN_EXPIRY")
-		expirySeconds, err := strconv.Atoi(expiryStr)
-		if err != nil || expirySeconds <= 0 {
-			expirySeconds = 3600
+		// Unknown addresses get the same response as known ones, and known
+		// ones get it before the token is saved and the email queued, so
+		// neither the answer nor its timing tells who has an account.
+		if user, err := c.Users.GetByEmail(email); err == nil {
+			go c.sendPasswordReset(user)
 		}
-		expiry := time.Now().Add(time.Duration(expirySeconds) * time.Second)
 
-		if err := internal.SavePasswordResetToken(user.ID, token, expiry); err != nil {
-			http.Error(w, "Failed to save reset token", http.StatusInternalServerError)
-			return
-		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	}
+}
 
-		go utils.SendPasswordResetEmail(user.Email, token)
+// sendPasswordReset gives the user a new reset token and queues the email
+// with the link. It runs after the response is sent, so errors are logged.
+func (c *Controller) sendPasswordReset(user *models.User) {
+	token := utils.GenerateToken()
+	expirySeconds, err := strconv.Atoi(os.Getenv("RESET_TOKEN_EXPIRY"))
+	if err != nil || expirySeconds <= 0 {
+		expirySeconds = 3600
+	}
+	expiry := time.Now().Add(time.Duration(expirySeconds) * time.Second)
 
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	if err := c.Users.SaveResetToken(user.ID, token, expiry); err != nil {
+		log.Printf("Error saving reset token of user %d: %v", user.ID, err)
+		return
+	}
+	if err := c.Mail.SendPasswordReset(user.Email, token); err != nil {
+		log.Printf("Error queueing password reset email: %v", err)
+	}
+}
+
+// checkPassword compares password with the signed-in user's under the login
+// lockout, so a session can't be used to guess the password any faster than
+// the login form. It writes the error response when it returns false.
+func (c *Controller) checkPassword(w http.ResponseWriter, user *models.User, password string) bool {
+	if locked := c.Limits.Lockout.Check(user.Username); locked > 0 {
+		tooManyRequests(w, locked)
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		c.Limits.Lockout.Fail(user.Username)
+		http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+		return false
 	}
+	c.Limits.Lockout.Reset(user.Username)
+	return true
 }
 
-func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		token := r.URL.Query().Get("token")
 		if token == "" {
@@ -192,9 +289,11 @@ func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
 		tmpl.Execute(w, struct {
 			Token         string
 			Authenticated bool
+			CSRFToken     string
 		}{
 			Token:         token,
 			Authenticated: authenticated,
+			CSRFToken:     internal.CSRFToken(r),
 		})
 		return
 	}
@@ -214,7 +313,7 @@ func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		user, err := internal.GetUserByResetToken(token)
+		user, err := c.Users.GetByResetToken(token)
 		if err != nil {
 			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
 			return
@@ -226,21 +325,25 @@ func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := internal.UpdateUserPassword(user.ID, string(hashedPassword)); err != nil {
+		if err := c.Users.UpdatePassword(user.ID, string(hashedPassword)); err != nil {
 			http.Error(w, "Failed to update password", http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		if err := c.Sessions.DeleteAllForUser(user.ID, 0); err != nil {
+			log.Printf("Error revoking sessions of user %d: %v", user.ID, err)
+		}
+
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 	}
 }
 
-func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := internal.Store.Get(r, "session")
-	session.Values["authenticated"] = false
-	session.Values["user_id"] = nil
-	session.Save(r, w)
+	session.Options.MaxAge = -1
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error deleting session: %v", err)
+	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
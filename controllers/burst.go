@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+)
+
+// BurstHandler saves the frames posted by the camera page's burst mode as an
+// animated GIF.
+func (c *Controller) BurstHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value(internal.UserIDKey).(int)
+	options := imaging.AnimationOptions{Delay: imaging.DefaultFrameDelay, Boomerang: r.FormValue("boomerang") == "true"}
+	var err error
+	if value := r.FormValue("delay"); value != "" {
+		options.Delay, err = strconv.Atoi(value)
+		if err != nil {
+			err = &imaging.InvalidAnimationError{Reason: "delay must be a whole number of milliseconds"}
+		}
+	}
+	var edits models.Edits
+	if err == nil {
+		edits, err = parseEdits(r)
+	}
+	if err == nil {
+		_, err = c.saveAnimation(userID, r.Form["frame"], r.FormValue("overlay"), edits, options)
+	}
+	if err != nil {
+		status, message := imageError(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+}
+
+// saveAnimation renders each frame with the edits and overlay and stores them
+// as one animated GIF, with its first frame as the still poster shown in the
+// gallery grid. Like strips, animations can't be re-rendered.
+func (c *Controller) saveAnimation(userID int, frameData []string, overlayName string, edits models.Edits, options imaging.AnimationOptions) (_ int, err error) {
+	if err := imaging.ValidateAnimation(len(frameData), options); err != nil {
+		return 0, err
+	}
+	if err := imaging.ValidateEdits(edits); err != nil {
+		return 0, err
+	}
+
+	animation := &models.Image{UserID: userID, Overlay: internal.OverlaySlug(overlayName), Layout: imaging.LayoutAnimation, Edits: edits}
+	if options.Boomerang {
+		animation.Layout = imaging.LayoutBoomerang
+	}
+	frames, err := c.renderFrames(frameData, animation.Overlay, edits, imaging.BurstFrameSize)
+	if err != nil {
+		return 0, err
+	}
+
+	data, width, err := imaging.EncodeAnimation(frames, options)
+	if err != nil {
+		return 0, err
+	}
+	animation.FilePath, err = internal.SaveAnimation(c.Files, data)
+	if err != nil {
+		return 0, fmt.Errorf("saving animation: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			c.removeFiles(animation.FilePath, animation.Renditions)
+		}
+	}()
+
+	poster := imaging.Resize(frames[0], width)
+	animation.Renditions, err = internal.SaveRenditions(c.Files, poster, animation.FilePath)
+	if err != nil {
+		return 0, fmt.Errorf("saving renditions: %w", err)
+	}
+	posterRendition, err := internal.SavePoster(c.Files, poster, animation.FilePath)
+	if err != nil {
+		return 0, fmt.Errorf("saving poster: %w", err)
+	}
+	animation.Renditions = append(animation.Renditions, posterRendition)
+
+	if err := c.Images.Create(animation); err != nil {
+		return 0, err
+	}
+	return animation.ID, nil
+}
@@ -1,29 +1,27 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"html/template"
+	"image"
+	"log"
 	"net/http"
 	"os"
 
 	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
 	"photo-booth.com/internal/models"
 )
 
-func CameraHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) CameraHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		files, err := os.ReadDir("static/img/overlays")
+		overlays, err := c.overlayGroups()
 		if err != nil {
 			http.Error(w, "Unable to load overlays", http.StatusInternalServerError)
 			return
 		}
 
-		var overlays []string
-		for _, file := range files {
-			if !file.IsDir() {
-				overlays = append(overlays, file.Name())
-			}
-		}
-
 		authenticated := r.Context().Value(internal.AuthenticatedKey).(bool)
 		userID, ok := r.Context().Value(internal.UserIDKey).(int)
 
@@ -32,11 +30,20 @@ func CameraHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		recentImages, err := internal.GetRecentImagesByUser(userID, 5)
+		stickers, err := internal.ListStickers()
+		if err != nil {
+			http.Error(w, "Unable to load stickers", http.StatusInternalServerError)
+			return
+		}
+
+		recentImages, err := c.Images.ListRecentByUser(userID, 5)
 		if err != nil {
 			http.Error(w, "Unable to load recent images", http.StatusInternalServerError)
 			return
 		}
+		for i := range recentImages {
+			recentImages[i].ResolveURLs(c.Files.URL)
+		}
 
 		tmpl, err := template.ParseFiles("templates/camera.html")
 		if err != nil {
@@ -44,33 +51,231 @@ func CameraHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		tmpl.Execute(w, struct {
-			Overlays      []string
+			Overlays      []overlayGroup
+			Filters       []string
+			Fonts         []string
+			Stickers      []string
 			Authenticated bool
 			RecentImages  []models.Image
-		}{Overlays: overlays, Authenticated: authenticated, RecentImages: recentImages})
+			CSRFToken     string
+		}{Overlays: overlays, Filters: imaging.Filters, Fonts: imaging.Fonts, Stickers: stickers, Authenticated: authenticated, RecentImages: recentImages, CSRFToken: internal.CSRFToken(r)})
 		return
 	}
 
 	if r.Method == http.MethodPost {
-		imageData := r.FormValue("image")
-		if imageData == "" {
-			http.Error(w, "No image data provided", http.StatusBadRequest)
-			return
+		userID := r.Context().Value(internal.UserIDKey).(int)
+		edits, err := parseEdits(r)
+		if err == nil {
+			_, err = c.saveCapture(userID, r.FormValue("image"), r.FormValue("overlay"), edits)
 		}
-
-		filePath, err := internal.SaveImageFromBase64(imageData)
 		if err != nil {
-			http.Error(w, "Unable to save image", http.StatusInternalServerError)
+			status, message := imageError(err)
+			http.Error(w, message, status)
 			return
 		}
 
-		session, _ := internal.Store.Get(r, "session")
-		userID := session.Values["user_id"].(int)
-		if err := internal.SaveImageInfo(filePath, userID); err != nil {
-			http.Error(w, "Unable to save image info", http.StatusInternalServerError)
+		http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+	}
+}
+
+// overlayGroup is a category of overlays on the camera page.
+type overlayGroup struct {
+	Category string
+	Overlays []models.Overlay
+}
+
+// overlayGroups groups the enabled overlays by category. Categories appear in
+// the order of their first overlay.
+func (c *Controller) overlayGroups() ([]overlayGroup, error) {
+	overlays, err := c.Overlays.List(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []overlayGroup
+	index := map[string]int{}
+	for _, overlay := range overlays {
+		overlay.URL = c.Files.URL(overlay.FilePath)
+		i, ok := index[overlay.Category]
+		if !ok {
+			i = len(groups)
+			index[overlay.Category] = i
+			groups = append(groups, overlayGroup{Category: overlay.Category})
+		}
+		groups[i].Overlays = append(groups[i].Overlays, overlay)
+	}
+	return groups, nil
+}
+
+var (
+	errNoImageData    = errors.New("no image data provided")
+	errNoOverlay      = errors.New("no overlay selected")
+	errUploadTooLarge = errors.New("upload is too large")
+)
+
+// saveCapture stores a frame captured by the camera page, sent as a base64
+// data URL. Captures always get an overlay.
+func (c *Controller) saveCapture(userID int, imageData, overlayName string, edits models.Edits) (int, error) {
+	if imageData == "" {
+		return 0, errNoImageData
+	}
+	if overlayName == "" {
+		return 0, errNoOverlay
+	}
+
+	capture, err := internal.DecodeImageFromBase64(imageData)
+	if err != nil {
+		return 0, err
+	}
+	return c.saveImage(userID, capture, overlayName, edits)
+}
+
+// saveImage keeps the upload as the image's original, renders it with the
+// edits and overlay, if any, and returns the new image ID. Only the pixels
+// are stored; the EXIF capture time is kept when KEEP_CAPTURE_TIME=true.
+func (c *Controller) saveImage(userID int, upload *imaging.Upload, overlayName string, edits models.Edits) (_ int, err error) {
+	image := &models.Image{UserID: userID, Overlay: internal.OverlaySlug(overlayName), Edits: edits}
+	if err := c.render(image, upload.Image, upload.Format); err != nil {
+		return 0, err
+	}
+	// Files saved before a failure would never be linked to an image.
+	defer func() {
+		if err == nil {
 			return
 		}
+		c.removeFiles(image.FilePath, image.Renditions)
+		if image.OriginalPath != "" {
+			if err := c.Files.Delete(image.OriginalPath); err != nil {
+				log.Printf("Error deleting %s: %v", image.OriginalPath, err)
+			}
+		}
+	}()
 
-		http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+	image.OriginalPath, err = internal.SaveOriginal(c.Files, upload.Image, upload.Format)
+	if err != nil {
+		return 0, fmt.Errorf("saving original: %w", err)
+	}
+
+	if os.Getenv("KEEP_CAPTURE_TIME") == "true" {
+		image.CapturedAt = upload.CapturedAt
+	}
+
+	if err := c.Images.Create(image); err != nil {
+		return 0, err
+	}
+	return image.ID, nil
+}
+
+// render applies the image's edits, overlay and layers to original and
+// stores the result with its renditions in image.FilePath and
+// image.Renditions.
+func (c *Controller) render(image *models.Image, original image.Image, format string) error {
+	if err := imaging.ValidateEdits(image.Edits); err != nil {
+		return err
+	}
+
+	img := imaging.ApplyEdits(original, image.Edits)
+	if image.Overlay != "" {
+		overlay, err := internal.LoadOverlay(c.Overlays, c.Files, image.Overlay)
+		if err != nil {
+			return err
+		}
+		img = imaging.ApplyOverlay(img, overlay)
+	}
+	img, err := imaging.ApplyLayers(img, image.Edits.Layers, internal.LoadSticker)
+	if err != nil {
+		return err
+	}
+
+	filePath, err := internal.SaveImage(c.Files, img, format)
+	if err != nil {
+		return fmt.Errorf("saving image: %w", err)
+	}
+
+	renditions, err := internal.SaveRenditions(c.Files, img, filePath)
+	if err != nil {
+		return fmt.Errorf("saving renditions: %w", err)
+	}
+
+	image.FilePath = filePath
+	image.Renditions = renditions
+	return nil
+}
+
+// renderFrames decodes captures sent as base64 data URLs and applies the
+// edits, overlay and layers to each of them. The edits must be validated
+// already. With a maxSize, each capture is scaled down right after decoding
+// so that neither side is larger, keeping only one full-size frame in memory.
+func (c *Controller) renderFrames(frameData []string, overlaySlug string, edits models.Edits, maxSize int) ([]image.Image, error) {
+	var overlay image.Image
+	if overlaySlug != "" {
+		var err error
+		overlay, err = internal.LoadOverlay(c.Overlays, c.Files, overlaySlug)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	frames := make([]image.Image, len(frameData))
+	for i, data := range frameData {
+		if data == "" {
+			return nil, errNoImageData
+		}
+		capture, err := internal.DecodeImageFromBase64(data)
+		if err != nil {
+			return nil, err
+		}
+		if maxSize > 0 {
+			capture.Image = imaging.Fit(capture.Image, maxSize)
+		}
+		frames[i] = imaging.ApplyEdits(capture.Image, edits)
+		if overlay != nil {
+			frames[i] = imaging.ApplyOverlay(frames[i], overlay)
+		}
+		frames[i], err = imaging.ApplyLayers(frames[i], edits.Layers, internal.LoadSticker)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return frames, nil
+}
+
+// imageError maps an error from saving a capture or upload to a status code
+// and message.
+func imageError(err error) (int, string) {
+	var invalidEdits *imaging.InvalidEditsError
+	var invalidStrip *imaging.InvalidStripError
+	var invalidAnimation *imaging.InvalidAnimationError
+	switch {
+	case errors.Is(err, errNoImageData):
+		return http.StatusBadRequest, "No image data provided"
+	case errors.Is(err, errNoOverlay):
+		return http.StatusBadRequest, "No overlay selected"
+	case errors.Is(err, errUploadTooLarge):
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("Images can be at most %d MB", internal.MaxUploadSize>>20)
+	case errors.As(err, &invalidEdits):
+		return http.StatusBadRequest, "Invalid edits: " + invalidEdits.Reason
+	case errors.As(err, &invalidStrip):
+		return http.StatusBadRequest, "Invalid strip: " + invalidStrip.Reason
+	case errors.As(err, &invalidAnimation):
+		return http.StatusBadRequest, "Invalid animation: " + invalidAnimation.Reason
+	case errors.Is(err, imaging.ErrAnimationTooLarge):
+		return http.StatusBadRequest, fmt.Sprintf("Animations can be at most %d MB; send fewer frames", imaging.MaxAnimationSize>>20)
+	case errors.Is(err, errComposed):
+		return http.StatusConflict, "Photo strips and animations can't be edited"
+	case errors.Is(err, errNoOriginal):
+		return http.StatusConflict, "This image was saved before originals were kept and can't be edited"
+	case errors.Is(err, internal.ErrOverlayNotFound):
+		return http.StatusBadRequest, "Unknown overlay"
+	case errors.Is(err, internal.ErrStickerNotFound):
+		return http.StatusBadRequest, "Unknown sticker"
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType, "Only JPEG, PNG, WebP and GIF images are supported"
+	case errors.Is(err, imaging.ErrImageTooLarge):
+		return http.StatusBadRequest, fmt.Sprintf("Images can be at most %dx%d pixels", imaging.MaxDimension, imaging.MaxDimension)
+	case errors.Is(err, imaging.ErrInvalidImage):
+		return http.StatusBadRequest, "Invalid image data"
 	}
+	log.Printf("Error saving image: %v", err)
+	return http.StatusInternalServerError, "Unable to save image"
 }
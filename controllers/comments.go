@@ -1,14 +1,14 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
 	"photo-booth.com/internal"
-	"photo-booth.com/internal/utils"
 )
 
-func AddComment(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) AddComment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
@@ -29,21 +29,33 @@ func AddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = internal.AddComment(imageID, userID, content)
-	if err != nil {
+	if _, err := c.addComment(imageID, userID, content); err != nil {
 		http.Error(w, "Failed to add comment", http.StatusInternalServerError)
 		return
 	}
 
-	author, err := internal.GetImageAuthor(imageID)
+	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+}
+
+// addComment stores the comment and emails the image author if they asked to
+// be notified.
+func (c *Controller) addComment(imageID, userID int, content string) (int, error) {
+	commentID, err := c.Comments.Add(imageID, userID, content)
 	if err != nil {
-		http.Error(w, "Failed to get image author", http.StatusInternalServerError)
-		return
+		return 0, err
+	}
+
+	author, err := c.Images.GetAuthor(imageID)
+	if err != nil {
+		log.Printf("Error loading author of image %d: %v", imageID, err)
+		return commentID, nil
 	}
 
 	if author.NotifyOnComment {
-		go utils.SendCommentNotification(author.Email, content)
+		if err := c.Mail.SendCommentNotification(author.Email, author.Username, content); err != nil {
+			log.Printf("Error queueing comment notification: %v", err)
+		}
 	}
 
-	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+	return commentID, nil
 }
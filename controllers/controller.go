@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"photo-booth.com/internal/mail"
+	"photo-booth.com/internal/ratelimit"
+	"photo-booth.com/internal/sso"
+	"photo-booth.com/internal/storage"
+	"photo-booth.com/internal/store"
+)
+
+type Controller struct {
+	Users      store.UserStore
+	Images     store.ImageStore
+	Comments   store.CommentStore
+	Likes      store.LikeStore
+	Sessions   store.SessionStore
+	TwoFactor  store.TwoFactorStore
+	Identities store.IdentityStore
+	APITokens  store.APITokenStore
+	Overlays   store.OverlayStore
+	Files      storage.Storage
+	Mail       *mail.Service
+	Limits     *ratelimit.Auth
+	Providers  []*sso.Provider
+}
+
+func New(stores store.Stores, files storage.Storage, mailService *mail.Service, limits *ratelimit.Auth, providers []*sso.Provider) *Controller {
+	return &Controller{
+		Users:      stores.Users,
+		Images:     stores.Images,
+		Comments:   stores.Comments,
+		Likes:      stores.Likes,
+		Sessions:   stores.Sessions,
+		TwoFactor:  stores.TwoFactor,
+		Identities: stores.Identities,
+		APITokens:  stores.APITokens,
+		Overlays:   stores.Overlays,
+		Files:      files,
+		Mail:       mailService,
+		Limits:     limits,
+		Providers:  providers,
+	}
+}
@@ -0,0 +1,432 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/mail"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/ratelimit"
+	"photo-booth.com/internal/storage"
+	"photo-booth.com/internal/store"
+)
+
+// The handlers parse their templates relative to the repository root.
+func TestMain(m *testing.M) {
+	if err := os.Chdir(".."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestController returns a controller backed by the in-memory stores and
+// local storage in a temporary directory.
+func newTestController(t *testing.T) (*Controller, store.Stores) {
+	t.Helper()
+	files, err := storage.NewLocal(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := store.NewMemory()
+	return New(stores, files, nil, nil, nil), stores
+}
+
+func createUser(t *testing.T, stores store.Stores, username string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Email: username + "@example.com", Password: "x", IsConfirmed: true}
+	if err := stores.Users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func createImage(t *testing.T, stores store.Stores, userID int) *models.Image {
+	t.Helper()
+	image := &models.Image{UserID: userID, FilePath: fmt.Sprintf("photo_%d.png", userID)}
+	if err := stores.Images.Create(image); err != nil {
+		t.Fatal(err)
+	}
+	return image
+}
+
+// asUser attaches the signed-in user to r the way AuthMiddleware does.
+func asUser(r *http.Request, userID int) *http.Request {
+	ctx := context.WithValue(r.Context(), internal.AuthenticatedKey, userID != 0)
+	ctx = context.WithValue(ctx, internal.UserIDKey, userID)
+	return r.WithContext(ctx)
+}
+
+func postForm(path string, values url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestLikeImageHandler(t *testing.T) {
+	c, stores := newTestController(t)
+	alice := createUser(t, stores, "alice")
+	image := createImage(t, stores, alice.ID)
+	imageID := fmt.Sprint(image.ID)
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"like", postForm("/like", url.Values{"image_id": {imageID}}), http.StatusSeeOther},
+		{"like again", postForm("/like", url.Values{"image_id": {imageID}}), http.StatusConflict},
+		{"missing image", postForm("/like", url.Values{}), http.StatusBadRequest},
+		{"invalid image", postForm("/like", url.Values{"image_id": {"abc"}}), http.StatusBadRequest},
+		{"wrong method", httptest.NewRequest(http.MethodGet, "/like?image_id="+imageID, nil), http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c.LikeImageHandler(w, asUser(tt.request, alice.ID))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+
+	images, err := stores.Images.ListPage(alice.ID, store.Cursor{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images[0].Likes != 1 {
+		t.Errorf("image has %d likes, want 1", images[0].Likes)
+	}
+}
+
+var (
+	renderedImages = regexp.MustCompile(`<img src="/uploads/photo_\d+\.png"`)
+	likeForms      = regexp.MustCompile(`<form action="/like" method="POST" class="like-form">\s*<input type="hidden" name="csrf_token" value="[^"]*">\s*<input type="hidden" name="image_id" value="\d+">`)
+)
+
+func TestGalleryHandler(t *testing.T) {
+	c, stores := newTestController(t)
+	alice := createUser(t, stores, "alice")
+	bob := createUser(t, stores, "bob")
+	for i := 0; i < 25; i++ {
+		owner := alice
+		if i%2 == 1 {
+			owner = bob
+		}
+		createImage(t, stores, owner.ID)
+	}
+	// The newest image is on the first page.
+	newest := createImage(t, stores, bob.ID)
+	if _, err := stores.Comments.Add(newest.ID, alice.ID, "Great shot!"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c.GalleryHandler(w, asUser(httptest.NewRequest(http.MethodGet, "/gallery", nil), alice.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	if got := len(renderedImages.FindAllString(body, -1)); got != 20 {
+		t.Errorf("first page shows %d images, want 20", got)
+	}
+	if got := len(likeForms.FindAllString(body, -1)); got != 20 {
+		t.Errorf("first page has %d like forms, want 20", got)
+	}
+	if !strings.Contains(body, "<strong>alice:</strong> Great shot!") {
+		t.Error("first page is missing the comment on the newest image")
+	}
+
+	cursor := regexp.MustCompile(`data-next-cursor="([^"]+)"`).FindStringSubmatch(body)
+	if cursor == nil {
+		t.Fatal("first page has no next cursor")
+	}
+
+	w = httptest.NewRecorder()
+	c.GalleryHandler(w, httptest.NewRequest(http.MethodGet, "/gallery?cursor="+url.QueryEscape(cursor[1]), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("second page status = %d: %s", w.Code, w.Body)
+	}
+	body = w.Body.String()
+	if got := len(renderedImages.FindAllString(body, -1)); got != 6 {
+		t.Errorf("second page shows %d images, want 6", got)
+	}
+	if !strings.Contains(body, `data-next-cursor=""`) {
+		t.Error("second page should be the last")
+	}
+	if likeForms.MatchString(body) {
+		t.Error("visitors should not get like forms")
+	}
+
+	w = httptest.NewRecorder()
+	c.GalleryHandler(w, httptest.NewRequest(http.MethodGet, "/gallery?cursor=not-a-cursor", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid cursor status = %d, want 400", w.Code)
+	}
+}
+
+// TestCommentMarkup checks that a comment containing HTML reaches the gallery
+// as text, both on the rendered page and in the API data the page's infinite
+// scroll appends.
+func TestCommentMarkup(t *testing.T) {
+	c, stores := newTestController(t)
+	c.Mail = mail.NewService(&mail.CaptureMailer{}, "http://localhost")
+	alice := createUser(t, stores, "alice")
+	image := createImage(t, stores, alice.ID)
+	const markup = `<img src=x onerror="alert(1)">`
+
+	w := httptest.NewRecorder()
+	c.AddComment(w, asUser(postForm("/comments/add", url.Values{"image_id": {fmt.Sprint(image.ID)}, "content": {markup}}), alice.ID))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("adding comment: status %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	c.GalleryHandler(w, asUser(httptest.NewRequest(http.MethodGet, "/gallery", nil), alice.ID))
+	body := w.Body.String()
+	if strings.Contains(body, markup) {
+		t.Error("gallery page renders the comment as markup")
+	}
+	if !strings.Contains(body, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;") {
+		t.Error("gallery page is missing the escaped comment")
+	}
+	// The page's script must build the images it loads from the API out of
+	// text nodes and properties, not by parsing strings as HTML.
+	if strings.Contains(body, "innerHTML") || strings.Contains(body, "insertAdjacentHTML") {
+		t.Error("gallery script parses API data as HTML")
+	}
+
+	w = httptest.NewRecorder()
+	c.APIHandler(w, asUser(httptest.NewRequest(http.MethodGet, "/api/v1/images", nil), alice.ID))
+	var page struct {
+		Images []struct {
+			Comments []struct {
+				Content string `json:"content"`
+			} `json:"comments"`
+		} `json:"images"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("decoding API response: %v", err)
+	}
+	if len(page.Images) != 1 || len(page.Images[0].Comments) != 1 || page.Images[0].Comments[0].Content != markup {
+		t.Errorf("API images = %+v, want the comment as plain text", page.Images)
+	}
+}
+
+// TestPrivateFiles checks that originals and strip frames are only served to
+// the owner of their image, and never as public uploads.
+func TestPrivateFiles(t *testing.T) {
+	c, stores := newTestController(t)
+	alice := createUser(t, stores, "alice")
+	bob := createUser(t, stores, "bob")
+	for key, data := range map[string]string{"originals/photo_a.png": "original", "frames/photo_b.jpg": "frame"} {
+		if err := c.Files.Put(key, strings.NewReader(data), "image/png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	image := &models.Image{
+		UserID:       alice.ID,
+		FilePath:     "photo_c.jpg",
+		OriginalPath: "originals/photo_a.png",
+		Frames:       []models.Frame{{Position: 0, FilePath: "frames/photo_b.jpg"}},
+	}
+	if err := stores.Images.Create(image); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+		userID  int
+		want    int
+		body    string
+	}{
+		{"owner gets the original", c.OriginalHandler, "/images/original?image_id=%d", alice.ID, http.StatusOK, "original"},
+		{"others don't get the original", c.OriginalHandler, "/images/original?image_id=%d", bob.ID, http.StatusForbidden, ""},
+		{"owner gets a frame", c.FrameHandler, "/images/frame?image_id=%d&position=0", alice.ID, http.StatusOK, "frame"},
+		{"others don't get a frame", c.FrameHandler, "/images/frame?image_id=%d&position=0", bob.ID, http.StatusForbidden, ""},
+		{"missing frame", c.FrameHandler, "/images/frame?image_id=%d&position=3", alice.ID, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, asUser(httptest.NewRequest(http.MethodGet, fmt.Sprintf(tt.path, image.ID), nil), tt.userID))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body, tt.body)
+			}
+		})
+	}
+
+	uploads := http.StripPrefix("/uploads", storage.Handler(c.Files))
+	for _, key := range []string{image.OriginalPath, image.Frames[0].FilePath} {
+		w := httptest.NewRecorder()
+		uploads.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/uploads/"+key, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("/uploads/%s: status %d, want 404", key, w.Code)
+		}
+	}
+}
+
+// failingImages is an image store that can't save new images.
+type failingImages struct {
+	store.ImageStore
+}
+
+func (failingImages) Create(*models.Image) error {
+	return errors.New("database is down")
+}
+
+// TestSaveImageCleanup checks that no files are left behind when the image
+// can't be recorded.
+func TestSaveImageCleanup(t *testing.T) {
+	dir := t.TempDir()
+	files, err := storage.NewLocal(dir, "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(store.NewMemory(), files, nil, nil, nil)
+	c.Images = failingImages{c.Images}
+	alice := createUser(t, store.Stores{Users: c.Users}, "alice")
+
+	upload := &imaging.Upload{Image: image.NewRGBA(image.Rect(0, 0, 64, 48)), Format: imaging.FormatPNG}
+	if _, err := c.saveImage(alice.ID, upload, "", models.Edits{}); err == nil {
+		t.Fatal("saveImage succeeded without a database")
+	}
+
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			t.Errorf("file left behind: %s", path)
+		}
+		return err
+	})
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	c, stores := newTestController(t)
+	mailer := &mail.CaptureMailer{}
+	c.Mail = mail.NewService(mailer, "http://localhost")
+	c.Limits = ratelimit.NewAuth(ratelimit.NewMemoryStore())
+	createUser(t, stores, "alice")
+
+	for _, email := range []string{"nobody@example.com", " alice@example.com "} {
+		w := httptest.NewRecorder()
+		c.ResetPasswordHandler(w, postForm("/reset_password", url.Values{"email": {email}}))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+			t.Errorf("%q: status %d, location %q, want a redirect to /login", email, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	// The email is queued after the response.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(mailer.Messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "alice@example.com" {
+		t.Errorf("sent %+v, want one email to alice", messages)
+	}
+}
+
+// TestChangePasswordLockout checks that the current password asked for on
+// the settings page can't be guessed past the login lockout.
+func TestChangePasswordLockout(t *testing.T) {
+	c, stores := newTestController(t)
+	c.Limits = ratelimit.NewAuth(ratelimit.NewMemoryStore())
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: string(hash), IsConfirmed: true}
+	if err := stores.Users.Create(alice); err != nil {
+		t.Fatal(err)
+	}
+
+	change := func(current string) int {
+		w := httptest.NewRecorder()
+		values := url.Values{"current_password": {current}, "new_password": {"battery staple"}, "confirm_password": {"battery staple"}}
+		c.SettingsHandler(w, asUser(postForm("/settings", values), alice.ID))
+		return w.Code
+	}
+	for i := 0; i < c.Limits.Lockout.Threshold; i++ {
+		if code := change("guess"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password %d: status %d, want 401", i+1, code)
+		}
+	}
+	if code := change("correct horse"); code != http.StatusTooManyRequests {
+		t.Errorf("correct password while locked: status %d, want 429", code)
+	}
+	if locked := c.Limits.Lockout.Check("alice"); locked == 0 {
+		t.Error("the login form isn't locked for alice")
+	}
+}
+
+// TestChangePasswordHandler checks that setting a new password from a reset
+// link signs out the account's sessions and sends the user to the login form.
+func TestChangePasswordHandler(t *testing.T) {
+	c, stores := newTestController(t)
+	alice := createUser(t, stores, "alice")
+	if err := stores.Users.SaveResetToken(alice.ID, "reset-me", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	session := &models.Session{TokenHash: "signed-in", UserID: alice.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := stores.Sessions.Create(session); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	values := url.Values{"token": {"reset-me"}, "new_password": {"battery staple"}, "confirm_password": {"battery staple"}}
+	c.ChangePasswordHandler(w, postForm("/change_password", values))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+		t.Fatalf("status %d, location %q, want a redirect to /login", w.Code, w.Header().Get("Location"))
+	}
+	if _, err := stores.Sessions.GetByTokenHash("signed-in"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("session after the password change: err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestSettingsMarkup checks that text other people or other devices control,
+// like session addresses and token names, is escaped on the settings page.
+func TestSettingsMarkup(t *testing.T) {
+	c, stores := newTestController(t)
+	internal.Store = internal.NewSessionStore(stores.Sessions, []byte("test-secret"))
+	alice := createUser(t, stores, "alice")
+	const markup = `<img src=x onerror="alert(1)">`
+	session := &models.Session{TokenHash: "signed-in", UserID: alice.ID, UserAgent: markup, IP: markup, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := stores.Sessions.Create(session); err != nil {
+		t.Fatal(err)
+	}
+	token := &models.APIToken{UserID: alice.ID, Name: markup, TokenHash: "token", Scopes: []string{models.ScopeRead}}
+	if err := stores.APITokens.Create(token); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c.SettingsHandler(w, asUser(httptest.NewRequest(http.MethodGet, "/settings", nil), alice.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), markup) {
+		t.Error("settings page renders markup unescaped")
+	}
+}
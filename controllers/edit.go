@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+)
+
+var (
+	errNoOriginal = errors.New("image has no original")
+	errComposed   = errors.New("image is composed from several captures")
+)
+
+// EditImageHandler shows the edit page for one of the user's images and
+// re-renders the image from its original with the posted overlay and edits.
+func (c *Controller) EditImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	image, ok := c.ownImage(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		edits, err := parseEdits(r)
+		if err == nil {
+			err = c.rerender(image, r.FormValue("overlay"), edits)
+		}
+		if err != nil {
+			status, message := imageError(err)
+			http.Error(w, message, status)
+			return
+		}
+		http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+		return
+	}
+
+	if err := editable(image); err != nil {
+		status, message := imageError(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	overlays, err := c.overlayGroups()
+	if err != nil {
+		http.Error(w, "Unable to load overlays", http.StatusInternalServerError)
+		return
+	}
+	image.ResolveURLs(c.Files.URL)
+
+	// The layers are edited as the JSON list the upload takes. The template
+	// escapes it, so the encoder doesn't need to.
+	var layers strings.Builder
+	if len(image.Edits.Layers) > 0 {
+		encoder := json.NewEncoder(&layers)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(image.Edits.Layers); err != nil {
+			http.Error(w, "Unable to load edit page", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tmpl, err := template.ParseFiles("templates/edit_image.html")
+	if err != nil {
+		http.Error(w, "Unable to load edit page", http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, struct {
+		Image         *models.Image
+		Overlays      []overlayGroup
+		Filters       []string
+		Layers        string
+		Authenticated bool
+		CSRFToken     string
+	}{Image: image, Overlays: overlays, Filters: imaging.Filters, Layers: layers.String(), Authenticated: true, CSRFToken: internal.CSRFToken(r)})
+}
+
+// RevertImageHandler renders one of the user's images from its original
+// again, without overlay or edits.
+func (c *Controller) RevertImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	image, ok := c.ownImage(w, r)
+	if !ok {
+		return
+	}
+	if err := c.rerender(image, "", models.Edits{}); err != nil {
+		status, message := imageError(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+}
+
+// ownImage loads the image named by the image_id form value and checks that
+// it belongs to the signed-in user.
+func (c *Controller) ownImage(w http.ResponseWriter, r *http.Request) (*models.Image, bool) {
+	userID := r.Context().Value(internal.UserIDKey).(int)
+
+	imageID, err := strconv.Atoi(r.FormValue("image_id"))
+	if err != nil {
+		http.Error(w, "Invalid image ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	image, err := c.Images.GetByID(imageID)
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return nil, false
+	}
+	if image.UserID != userID {
+		http.Error(w, "You are not authorized to edit this image", http.StatusForbidden)
+		return nil, false
+	}
+	return image, true
+}
+
+// rerender renders the image from its original with a new overlay and edits,
+// then removes the files of the previous rendering. The original is never
+// changed.
+func (c *Controller) rerender(image *models.Image, overlayName string, edits models.Edits) error {
+	if err := editable(image); err != nil {
+		return err
+	}
+
+	previous, err := c.Images.Renditions(image.ID)
+	if err != nil {
+		return err
+	}
+	previousPath := image.FilePath
+
+	original, format, err := internal.LoadOriginal(c.Files, image.OriginalPath)
+	if err != nil {
+		return err
+	}
+
+	image.Overlay = internal.OverlaySlug(overlayName)
+	image.Edits = edits
+	if err := c.render(image, original, format); err != nil {
+		return err
+	}
+
+	if err := c.Images.UpdateRender(image); err != nil {
+		c.removeFiles(image.FilePath, image.Renditions)
+		return err
+	}
+	c.removeFiles(previousPath, previous)
+	return nil
+}
+
+// editable reports why an image can't be re-rendered, if it can't.
+func editable(image *models.Image) error {
+	if image.Layout != "" {
+		return errComposed
+	}
+	if image.OriginalPath == "" {
+		return errNoOriginal
+	}
+	return nil
+}
+
+// removeFiles deletes a rendered image and its renditions. Failures only
+// leave unused files behind, so they are logged.
+func (c *Controller) removeFiles(filePath string, renditions []models.Rendition) {
+	keys := []string{filePath}
+	for _, rendition := range renditions {
+		if rendition.FilePath != filePath {
+			keys = append(keys, rendition.FilePath)
+		}
+	}
+	for _, key := range keys {
+		if err := c.Files.Delete(key); err != nil {
+			log.Printf("Error deleting %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+)
+
+// parseEdits reads the filter, adjustments and layers posted with a capture
+// or upload. Missing fields leave the photo unchanged; a crop needs all of
+// crop_x, crop_y, crop_width and crop_height, and layers are a JSON list.
+func parseEdits(r *http.Request) (models.Edits, error) {
+	edits := models.Edits{Filter: strings.TrimSpace(r.FormValue("filter"))}
+
+	for _, field := range []struct {
+		name  string
+		value *float64
+	}{
+		{"brightness", &edits.Brightness},
+		{"contrast", &edits.Contrast},
+		{"saturation", &edits.Saturation},
+	} {
+		if err := parseEditValue(r, field.name, field.value); err != nil {
+			return models.Edits{}, err
+		}
+	}
+
+	if value := r.FormValue("rotate"); value != "" {
+		rotate, err := strconv.Atoi(value)
+		if err != nil {
+			return models.Edits{}, &imaging.InvalidEditsError{Reason: "rotate must be a whole number of degrees"}
+		}
+		edits.Rotate = rotate
+	}
+
+	if r.FormValue("crop_x") != "" || r.FormValue("crop_y") != "" || r.FormValue("crop_width") != "" || r.FormValue("crop_height") != "" {
+		crop := &models.Crop{}
+		for _, field := range []struct {
+			name  string
+			value *float64
+		}{
+			{"crop_x", &crop.X},
+			{"crop_y", &crop.Y},
+			{"crop_width", &crop.Width},
+			{"crop_height", &crop.Height},
+		} {
+			if r.FormValue(field.name) == "" {
+				return models.Edits{}, &imaging.InvalidEditsError{Reason: field.name + " is required for a crop"}
+			}
+			if err := parseEditValue(r, field.name, field.value); err != nil {
+				return models.Edits{}, err
+			}
+		}
+		edits.Crop = crop
+	}
+
+	if value := strings.TrimSpace(r.FormValue("layers")); value != "" {
+		decoder := json.NewDecoder(strings.NewReader(value))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&edits.Layers); err != nil {
+			return models.Edits{}, &imaging.InvalidEditsError{Reason: "layers must be a JSON list of layers"}
+		}
+	}
+
+	return edits, nil
+}
+
+func parseEditValue(r *http.Request, name string, value *float64) error {
+	raw := r.FormValue(name)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return &imaging.InvalidEditsError{Reason: name + " must be a number"}
+	}
+	*value = parsed
+	return nil
+}
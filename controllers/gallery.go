@@ -1,38 +1,43 @@
 package controllers
 
 import (
-	"encoding/json"
 	"html/template"
 	"net/http"
-	"strconv"
 
 	"photo-booth.com/internal"
 	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
 )
 
-func GalleryHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) GalleryHandler(w http.ResponseWriter, r *http.Request) {
 	authenticated, _ := r.Context().Value(internal.AuthenticatedKey).(bool)
 	userID, _ := r.Context().Value(internal.UserIDKey).(int)
 
-	pageStr := r.URL.Query().Get("page")
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
+	var after store.Cursor
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		cursor, err := store.DecodeCursor(token)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		after = cursor
 	}
 
 	limit := 20
-	offset := (page - 1) * limit
 
-	images, err := internal.GetImagesPaginated(userID, limit, offset)
+	images, err := c.Images.ListPage(userID, after, limit+1)
 	if err != nil {
 		http.Error(w, "Unable to retrieve images", http.StatusInternalServerError)
 		return
 	}
 
-	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(images)
-		return
+	nextCursor := ""
+	if len(images) > limit {
+		images = images[:limit]
+		nextCursor = store.CursorFor(images[limit-1]).Encode()
+	}
+	for i := range images {
+		images[i].ResolveURLs(c.Files.URL)
 	}
 
 	tmpl, err := template.ParseFiles("templates/gallery.html")
@@ -43,11 +48,18 @@ func GalleryHandler(w http.ResponseWriter, r *http.Request) {
 
 	data := struct {
 		Images        []models.Image
+		NextCursor    string
 		Authenticated bool
+		CSRFToken     string
 	}{
 		Images:        images,
+		NextCursor:    nextCursor,
 		Authenticated: authenticated,
 	}
+	// Visitors get no forms, so their views don't create sessions.
+	if authenticated {
+		data.CSRFToken = internal.CSRFToken(r)
+	}
 
 	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, "Unable to render template", http.StatusInternalServerError)
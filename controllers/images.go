@@ -1,14 +1,70 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
-	"os"
 	"strconv"
 
 	"photo-booth.com/internal"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/storage"
 )
 
-func DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
+// OriginalHandler serves the untouched original of an image to its owner.
+// Originals are not public, so they are never linked under /uploads.
+func (c *Controller) OriginalHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	image, ok := c.ownImage(w, r)
+	if !ok {
+		return
+	}
+	if image.OriginalPath == "" {
+		http.Error(w, "This image has no original", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private")
+	storage.Serve(w, r, c.Files, image.OriginalPath)
+}
+
+// FrameHandler serves one capture of a photo strip to the strip's owner.
+func (c *Controller) FrameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	image, ok := c.ownImage(w, r)
+	if !ok {
+		return
+	}
+	position, err := strconv.Atoi(r.FormValue("position"))
+	if err != nil {
+		http.Error(w, "Invalid frame position", http.StatusBadRequest)
+		return
+	}
+
+	frames, err := c.Images.Frames(image.ID)
+	if err != nil {
+		log.Printf("Error loading frames of image %d: %v", image.ID, err)
+		http.Error(w, "Unable to load frame", http.StatusInternalServerError)
+		return
+	}
+	for _, frame := range frames {
+		if frame.Position == position {
+			w.Header().Set("Cache-Control", "private")
+			storage.Serve(w, r, c.Files, frame.FilePath)
+			return
+		}
+	}
+	http.Error(w, "Frame not found", http.StatusNotFound)
+}
+
+func (c *Controller) DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
@@ -27,7 +83,7 @@ func DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	image, err := internal.GetImageByID(imageID)
+	image, err := c.Images.GetByID(imageID)
 	if err != nil {
 		http.Error(w, "Image not found", http.StatusNotFound)
 		return
@@ -37,17 +93,45 @@ func DeleteImageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = os.Remove(image.FilePath)
-	if err != nil {
-		http.Error(w, "Failed to delete image file", http.StatusInternalServerError)
+	if err := c.deleteImage(image); err != nil {
+		log.Printf("Error deleting image %d: %v", image.ID, err)
+		http.Error(w, "Failed to delete image", http.StatusInternalServerError)
 		return
 	}
 
-	err = internal.DeleteImageByID(imageID)
+	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+}
+
+// deleteImage removes the image file, its renditions, its original and the
+// database record.
+func (c *Controller) deleteImage(image *models.Image) error {
+	renditions, err := c.Images.Renditions(image.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Files.Delete(image.FilePath); err != nil {
+		return err
+	}
+	for _, rendition := range renditions {
+		if err := c.Files.Delete(rendition.FilePath); err != nil {
+			return err
+		}
+	}
+	if image.OriginalPath != "" {
+		if err := c.Files.Delete(image.OriginalPath); err != nil {
+			return err
+		}
+	}
+	frames, err := c.Images.Frames(image.ID)
 	if err != nil {
-		http.Error(w, "Failed to delete image record", http.StatusInternalServerError)
-		return
+		return err
+	}
+	for _, frame := range frames {
+		if err := c.Files.Delete(frame.FilePath); err != nil {
+			return err
+		}
 	}
 
-	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+	return c.Images.Delete(image.ID)
 }
@@ -1,27 +1,36 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"photo-booth.com/internal"
+	"photo-booth.com/internal/store"
 )
 
-func LikeImageHandler(w http.ResponseWriter, r *http.Request) {
+func (c *Controller) LikeImageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
 
-	imageID := r.FormValue("image_id")
+	imageIDStr := r.FormValue("image_id")
 	userID, ok := r.Context().Value(internal.UserIDKey).(int)
-	if imageID == "" || !ok || userID == 0 {
+	if imageIDStr == "" || !ok || userID == 0 {
 		http.Error(w, "Image ID and User ID are required", http.StatusBadRequest)
 		return
 	}
 
-	err := internal.AddLike(userID, imageID)
+	imageID, err := strconv.Atoi(imageIDStr)
 	if err != nil {
-		if err.Error() == "like already exists" {
+		http.Error(w, "Invalid image ID", http.StatusBadRequest)
+		return
+	}
+
+	err = c.Likes.Add(userID, imageID)
+	if err != nil {
+		if errors.Is(err, store.ErrLikeExists) {
 			http.Error(w, "You have already liked this image", http.StatusConflict)
 			return
 		}
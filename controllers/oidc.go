@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/sso"
+	"photo-booth.com/internal/store"
+	"photo-booth.com/internal/utils"
+)
+
+// oidcLoginTimeout is how long the user has to finish signing in at the
+// provider.
+const oidcLoginTimeout = 10 * time.Minute
+
+var (
+	errNoEmail    = errors.New("provider returned no email address")
+	errEmailTaken = errors.New("email already belongs to an account")
+	// errEmailUnverified is returned when the provider hasn't verified the
+	// address it shared, which accounts are confirmed by.
+	errEmailUnverified = errors.New("provider has not verified the email address")
+
+	usernameInvalidChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+)
+
+// OIDCHandler serves /auth/oidc/<provider>/login and
+// /auth/oidc/<provider>/callback.
+func (c *Controller) OIDCHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/auth/oidc/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	provider := sso.Find(c.Providers, parts[0])
+	if provider == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch parts[1] {
+	case "login":
+		c.oidcLogin(w, r, provider)
+	case "callback":
+		c.oidcCallback(w, r, provider)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (c *Controller) oidcLogin(w http.ResponseWriter, r *http.Request, provider *sso.Provider) {
+	state, nonce, verifier := sso.NewState(), sso.NewState(), sso.NewVerifier()
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error discovering OIDC provider %s: %v", provider.ID, err)
+		http.Error(w, "Sign-in provider is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	session, _ := internal.Store.Get(r, "session")
+	session.Values["oidc_provider"] = provider.ID
+	session.Values["oidc_state"] = state
+	session.Values["oidc_nonce"] = nonce
+	session.Values["oidc_verifier"] = verifier
+	session.Values["oidc_since"] = time.Now().Unix()
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error saving session: %v", err)
+		http.Error(w, "Unable to save session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (c *Controller) oidcCallback(w http.ResponseWriter, r *http.Request, provider *sso.Provider) {
+	session, _ := internal.Store.Get(r, "session")
+	providerID, _ := session.Values["oidc_provider"].(string)
+	state, _ := session.Values["oidc_state"].(string)
+	nonce, _ := session.Values["oidc_nonce"].(string)
+	verifier, _ := session.Values["oidc_verifier"].(string)
+	since, _ := session.Values["oidc_since"].(int64)
+
+	// The login attempt can only be completed once.
+	for _, key := range []string{"oidc_provider", "oidc_state", "oidc_nonce", "oidc_verifier", "oidc_since"} {
+		delete(session.Values, key)
+	}
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error saving session: %v", err)
+		http.Error(w, "Unable to save session", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	if providerID != provider.ID || state == "" || time.Since(time.Unix(since, 0)) > oidcLoginTimeout ||
+		subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		http.Error(w, "Invalid or expired sign-in attempt", http.StatusBadRequest)
+		return
+	}
+	if errCode := query.Get("error"); errCode != "" {
+		log.Printf("OIDC provider %s returned %s: %s", provider.ID, errCode, query.Get("error_description"))
+		http.Error(w, "Sign-in was cancelled or denied", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("Error completing OIDC sign-in with %s: %v", provider.ID, err)
+		http.Error(w, "Unable to verify sign-in", http.StatusBadRequest)
+		return
+	}
+
+	currentUserID := 0
+	if session.Values["authenticated"] == true {
+		currentUserID, _ = session.Values["user_id"].(int)
+	}
+
+	identity, err := c.Identities.Get(provider.ID, claims.Subject)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Unable to load linked account", http.StatusInternalServerError)
+		return
+	}
+
+	// Signed-in users are linking the provider account to their own.
+	if currentUserID != 0 {
+		if identity != nil && identity.UserID != currentUserID {
+			http.Error(w, "This "+provider.Name+" account is already linked to another user", http.StatusConflict)
+			return
+		}
+		if identity == nil {
+			identity = &models.Identity{UserID: currentUserID, Provider: provider.ID, Subject: claims.Subject, Email: claims.Email}
+			if err := c.Identities.Create(identity); err != nil {
+				http.Error(w, "Failed to link account", http.StatusInternalServerError)
+				return
+			}
+		}
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	if identity == nil {
+		userID, err := c.userForClaims(claims)
+		if errors.Is(err, errNoEmail) {
+			http.Error(w, provider.Name+" did not share an email address", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errEmailTaken) {
+			http.Error(w, "An account with this email already exists. Log in with your password and link your "+provider.Name+" account from the settings page.", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errEmailUnverified) {
+			http.Error(w, "Verify your email address with "+provider.Name+" before signing in, or register with a password", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Error creating user for OIDC sign-in with %s: %v", provider.ID, err)
+			http.Error(w, "Error creating user", http.StatusInternalServerError)
+			return
+		}
+
+		identity = &models.Identity{UserID: userID, Provider: provider.ID, Subject: claims.Subject, Email: claims.Email}
+		if err := c.Identities.Create(identity); err != nil {
+			http.Error(w, "Failed to link account", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	c.completeLogin(w, r, identity.UserID)
+}
+
+// userForClaims returns the user with the provider's verified email address,
+// or creates a confirmed account for it. An existing account is only joined
+// once it has confirmed the address, so registering someone else's email
+// doesn't give access to their sign-ins. An account is only created for an
+// address the provider has verified, since it skips the confirmation email.
+// The new account gets an unusable random password; the user can set one
+// through the password reset.
+func (c *Controller) userForClaims(claims *sso.Claims) (int, error) {
+	if claims.Email == "" {
+		return 0, errNoEmail
+	}
+
+	existing, err := c.Users.GetByEmail(claims.Email)
+	if err == nil {
+		if !claims.EmailVerified || !existing.IsConfirmed {
+			return 0, errEmailTaken
+		}
+		return existing.ID, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return 0, err
+	}
+	if !claims.EmailVerified {
+		return 0, errEmailUnverified
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(utils.GenerateToken()), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.Split(claims.Email, "@")[0]
+	}
+	base = strings.Trim(usernameInvalidChars.ReplaceAllString(strings.ToLower(base), ""), ".-")
+	if base == "" {
+		base = "user"
+	}
+
+	for i := 1; i <= 50; i++ {
+		username := base
+		if i > 1 {
+			username += strconv.Itoa(i)
+		}
+		if _, err := c.Users.GetByUsername(username); err == nil {
+			continue
+		}
+
+		user := models.User{
+			Username:    username,
+			Email:       claims.Email,
+			Password:    string(hashedPassword),
+			IsConfirmed: true,
+		}
+		err := c.Users.Create(&user)
+		if err == nil {
+			return user.ID, nil
+		}
+		if !errors.Is(err, store.ErrDuplicate) {
+			return 0, err
+		}
+		if _, err := c.Users.GetByEmail(claims.Email); err == nil {
+			return 0, errEmailTaken
+		}
+	}
+	return 0, errors.New("no free username for " + base)
+}
+
+func (c *Controller) UnlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(internal.UserIDKey).(int)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	identityID, err := strconv.Atoi(r.FormValue("identity_id"))
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	err = c.Identities.Delete(userID, identityID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Linked account not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to unlink account", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/sso"
+	"photo-booth.com/internal/store"
+)
+
+const (
+	testClientID     = "photo-booth"
+	testClientSecret = "client-secret"
+)
+
+type issuedCode struct {
+	challenge string
+	nonce     string
+	claims    map[string]any
+}
+
+// oidcStub is an OpenID Connect provider with discovery, a token endpoint
+// that enforces PKCE, and a JWKS endpoint for its RS256 signing key.
+type oidcStub struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	codes  map[string]issuedCode
+}
+
+func newOIDCStub(t *testing.T) *oidcStub {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &oidcStub{key: key, codes: map[string]issuedCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", stub.discovery)
+	mux.HandleFunc("/jwks", stub.jwks)
+	mux.HandleFunc("/token", stub.token)
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+// issue hands out an authorization code as the provider's login page would.
+func (s *oidcStub) issue(challenge, nonce string, claims map[string]any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := sso.NewState()
+	s.codes[code] = issuedCode{challenge: challenge, nonce: nonce, claims: claims}
+	return code
+}
+
+func (s *oidcStub) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"issuer":                                s.server.URL,
+		"authorization_endpoint":                s.server.URL + "/authorize",
+		"token_endpoint":                        s.server.URL + "/token",
+		"jwks_uri":                              s.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (s *oidcStub) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *oidcStub) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	// A code is only used up once it has been redeemed.
+	code := r.PostFormValue("code")
+	s.mu.Lock()
+	issued, ok := s.codes[code]
+	if ok && r.PostFormValue("grant_type") == "authorization_code" &&
+		oauth2.S256ChallengeFromVerifier(r.PostFormValue("code_verifier")) == issued.challenge {
+		delete(s.codes, code)
+	} else {
+		ok = false
+	}
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   s.server.URL,
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": issued.nonce,
+	}
+	for name, value := range issued.claims {
+		claims[name] = value
+	}
+	writeJSON(w, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     s.sign(claims),
+	})
+}
+
+func (s *oidcStub) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// oidcLogin is the state a login hands to the provider.
+type oidcLogin struct {
+	cookie    *http.Cookie
+	state     string
+	nonce     string
+	challenge string
+}
+
+func startOIDCLogin(t *testing.T, c *Controller) oidcLogin {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c.OIDCHandler(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d: %s", w.Code, w.Body)
+	}
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != testClientID {
+		t.Fatalf("authorization URL = %s", location)
+	}
+	return oidcLogin{
+		cookie:    w.Result().Cookies()[0],
+		state:     query.Get("state"),
+		nonce:     query.Get("nonce"),
+		challenge: query.Get("code_challenge"),
+	}
+}
+
+func TestOIDCHandler(t *testing.T) {
+	stub := newOIDCStub(t)
+	verified := func(subject, email string) map[string]any {
+		return map[string]any{"sub": subject, "email": email, "email_verified": true, "preferred_username": "Party.Goer"}
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		// callback turns the login and a code into the callback query. The
+		// default returns them the way the provider would.
+		callback func(login oidcLogin) (url.Values, *http.Cookie)
+		// issue overrides the challenge and nonce the code is issued for.
+		issue  func(login oidcLogin) (challenge, nonce string)
+		status int
+		// user is the username the identity must be linked to, or "" if no
+		// identity may be created.
+		user string
+	}{
+		{
+			name:   "new account",
+			claims: verified("new", "party@example.com"),
+			status: http.StatusSeeOther,
+			user:   "party.goer",
+		},
+		{
+			name:   "confirmed account is joined",
+			claims: verified("alice", "alice@example.com"),
+			status: http.StatusSeeOther,
+			user:   "alice",
+		},
+		{
+			name:   "unconfirmed account is refused",
+			claims: verified("carol", "carol@example.com"),
+			status: http.StatusConflict,
+		},
+		{
+			name:   "unverified email is refused",
+			claims: map[string]any{"sub": "unverified", "email": "alice@example.com", "email_verified": false},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "unverified email gets no new account",
+			claims: map[string]any{"sub": "unverified-new", "email": "dave@example.com", "email_verified": false, "preferred_username": "dave"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "state mismatch",
+			claims: verified("state", "state@example.com"),
+			callback: func(login oidcLogin) (url.Values, *http.Cookie) {
+				return url.Values{"state": {"forged"}}, login.cookie
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no login in the session",
+			claims: verified("session", "session@example.com"),
+			callback: func(login oidcLogin) (url.Values, *http.Cookie) {
+				return url.Values{"state": {login.state}}, nil
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "nonce mismatch",
+			claims: verified("nonce", "nonce@example.com"),
+			issue: func(login oidcLogin) (string, string) {
+				return login.challenge, sso.NewState()
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "code issued for another login",
+			claims: verified("pkce", "pkce@example.com"),
+			issue: func(login oidcLogin) (string, string) {
+				return oauth2.S256ChallengeFromVerifier(sso.NewVerifier()), login.nonce
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stores := newTestController(t)
+			internal.Store = internal.NewSessionStore(stores.Sessions, []byte("test-secret"))
+			c.Providers = []*sso.Provider{{
+				ID:           "mock",
+				Name:         "Mock",
+				Issuer:       stub.server.URL,
+				ClientID:     testClientID,
+				ClientSecret: testClientSecret,
+				RedirectURL:  "http://localhost/auth/oidc/mock/callback",
+				Scopes:       []string{"openid", "email", "profile"},
+			}}
+			alice := createUser(t, stores, "alice")
+			unconfirmed := &models.User{Username: "carol", Email: "carol@example.com", Password: "x", ConfirmationToken: "confirm-me"}
+			if err := stores.Users.Create(unconfirmed); err != nil {
+				t.Fatal(err)
+			}
+			existing := map[string]bool{alice.Email: true, unconfirmed.Email: true}
+
+			login := startOIDCLogin(t, c)
+			challenge, nonce := login.challenge, login.nonce
+			if tt.issue != nil {
+				challenge, nonce = tt.issue(login)
+			}
+			code := stub.issue(challenge, nonce, tt.claims)
+
+			query, cookie := url.Values{"state": {login.state}}, login.cookie
+			if tt.callback != nil {
+				query, cookie = tt.callback(login)
+			}
+			query.Set("code", code)
+
+			r := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?"+query.Encode(), nil)
+			if cookie != nil {
+				r.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			c.OIDCHandler(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			identity, err := stores.Identities.Get("mock", tt.claims["sub"].(string))
+			if tt.user == "" {
+				if !errors.Is(err, store.ErrNotFound) {
+					t.Errorf("identity = %+v, %v, want none", identity, err)
+				}
+				if email := tt.claims["email"].(string); !existing[email] {
+					if user, err := stores.Users.GetByEmail(email); !errors.Is(err, store.ErrNotFound) {
+						t.Errorf("account created for %s: %+v, %v", email, user, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("identity: %v", err)
+			}
+			user, err := stores.Users.GetByUsername(tt.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.UserID != user.ID || !user.IsConfirmed {
+				t.Errorf("identity linked to user %d, want confirmed %s (%d)", identity.UserID, tt.user, user.ID)
+			}
+			if location := w.Header().Get("Location"); location != "/gallery" {
+				t.Errorf("redirected to %q, want /gallery", location)
+			}
+		})
+	}
+}
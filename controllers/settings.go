@@ -1,15 +1,25 @@
 package controllers
 
 import (
+	"errors"
+	"html/template"
+	"log"
 	"net/http"
-	"text/template"
+	"strconv"
 
 	"golang.org/x/crypto/bcrypt"
 	"photo-booth.com/internal"
 	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/sso"
+	"photo-booth.com/internal/store"
 )
 
-func SettingsHandler(w http.ResponseWriter, r *http.Request) {
+type linkedAccount struct {
+	Provider *sso.Provider
+	Identity *models.Identity
+}
+
+func (c *Controller) SettingsHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(internal.UserIDKey).(int)
 	if !ok || userID == 0 {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -19,7 +29,7 @@ func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 	authenticated := r.Context().Value(internal.AuthenticatedKey).(bool)
 
 	if r.Method == http.MethodGet {
-		user, err := internal.GetUserByID(userID)
+		user, err := c.Users.GetByID(userID)
 		if err != nil {
 			http.Error(w, "Unable to load user data", http.StatusInternalServerError)
 			return
@@ -30,12 +40,65 @@ func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Unable to load settings page", http.StatusInternalServerError)
 			return
 		}
+		sessions, err := c.Sessions.ListByUser(userID)
+		if err != nil {
+			http.Error(w, "Unable to load sessions", http.StatusInternalServerError)
+			return
+		}
+		currentID := internal.Store.CurrentID(r)
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == currentID
+		}
+
+		identities, err := c.Identities.ListByUser(userID)
+		if err != nil {
+			http.Error(w, "Unable to load linked accounts", http.StatusInternalServerError)
+			return
+		}
+		var linkedAccounts []linkedAccount
+		for _, provider := range c.Providers {
+			account := linkedAccount{Provider: provider}
+			for i := range identities {
+				if identities[i].Provider == provider.ID {
+					account.Identity = &identities[i]
+				}
+			}
+			linkedAccounts = append(linkedAccounts, account)
+		}
+
+		apiTokens, err := c.APITokens.ListByUser(userID)
+		if err != nil {
+			http.Error(w, "Unable to load API tokens", http.StatusInternalServerError)
+			return
+		}
+
+		twoFactorEnabled := false
+		recoveryCodesLeft := 0
+		if tf, err := c.TwoFactor.Get(userID); err == nil && tf.Enabled {
+			twoFactorEnabled = true
+			recoveryCodesLeft, _ = c.TwoFactor.CountRecoveryCodes(userID)
+		}
+
 		tmpl.Execute(w, struct {
-			User          *models.User
-			Authenticated bool
+			User              *models.User
+			Sessions          []models.Session
+			LinkedAccounts    []linkedAccount
+			APITokens         []models.APIToken
+			Scopes            []string
+			TwoFactorEnabled  bool
+			RecoveryCodesLeft int
+			Authenticated     bool
+			CSRFToken         string
 		}{
-			User:          user,
-			Authenticated: authenticated,
+			User:              user,
+			Sessions:          sessions,
+			LinkedAccounts:    linkedAccounts,
+			APITokens:         apiTokens,
+			Scopes:            models.ScopesFor(user),
+			TwoFactorEnabled:  twoFactorEnabled,
+			RecoveryCodesLeft: recoveryCodesLeft,
+			Authenticated:     authenticated,
+			CSRFToken:         internal.CSRFToken(r),
 		})
 		return
 	}
@@ -48,7 +111,7 @@ func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 		confirmPassword := r.FormValue("confirm_password")
 
 		if username != "" || email != "" {
-			err := internal.UpdateUserProfile(userID, username, email)
+			err := c.Users.UpdateProfile(userID, username, email)
 			if err != nil {
 				http.Error(w, "Failed to update profile", http.StatusInternalServerError)
 				return
@@ -61,15 +124,13 @@ func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			user, err := internal.GetUserByID(userID)
+			user, err := c.Users.GetByID(userID)
 			if err != nil {
 				http.Error(w, "Unable to load user data", http.StatusInternalServerError)
 				return
 			}
 
-			err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword))
-			if err != nil {
-				http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+			if !c.checkPassword(w, user, currentPassword) {
 				return
 			}
 
@@ -79,13 +140,62 @@ func SettingsHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			err = internal.UpdateUserPassword(userID, string(hashedPassword))
+			err = c.Users.UpdatePassword(userID, string(hashedPassword))
 			if err != nil {
 				http.Error(w, "Failed to update password", http.StatusInternalServerError)
 				return
 			}
+
+			if err := c.Sessions.DeleteAllForUser(userID, internal.Store.CurrentID(r)); err != nil {
+				log.Printf("Error revoking sessions of user %d: %v", userID, err)
+			}
 		}
 
 		http.Redirect(w, r, "/settings", http.StatusSeeOther)
 	}
 }
+
+func (c *Controller) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(internal.UserIDKey).(int)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.FormValue("all") == "true" {
+		if err := c.Sessions.DeleteAllForUser(userID, internal.Store.CurrentID(r)); err != nil {
+			http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	sessionID, err := strconv.Atoi(r.FormValue("session_id"))
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	currentID := internal.Store.CurrentID(r)
+	err = c.Sessions.DeleteForUser(userID, sessionID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	if sessionID == currentID {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+)
+
+const (
+	defaultStripBorder  = 40
+	defaultStripSpacing = 20
+)
+
+// StripHandler saves the frames posted by the camera page's strip mode as one
+// photo strip.
+func (c *Controller) StripHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value(internal.UserIDKey).(int)
+	options, err := parseStripOptions(r)
+	var edits models.Edits
+	if err == nil {
+		edits, err = parseEdits(r)
+	}
+	if err == nil {
+		_, err = c.saveStrip(userID, r.Form["frame"], r.FormValue("overlay"), edits, options)
+	}
+	if err != nil {
+		status, message := imageError(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+}
+
+// defaultStripOptions is a vertical strip with a white border.
+func defaultStripOptions() imaging.StripOptions {
+	return imaging.StripOptions{
+		Layout:     imaging.LayoutStrip,
+		Border:     defaultStripBorder,
+		Spacing:    defaultStripSpacing,
+		Background: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+	}
+}
+
+// parseStripOptions reads the layout fields posted with a strip. Missing
+// fields keep their defaults.
+func parseStripOptions(r *http.Request) (imaging.StripOptions, error) {
+	options := defaultStripOptions()
+	if layout := r.FormValue("layout"); layout != "" {
+		options.Layout = layout
+	}
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{
+		{"border", &options.Border},
+		{"spacing", &options.Spacing},
+	} {
+		raw := r.FormValue(field.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return options, &imaging.InvalidStripError{Reason: field.name + " must be a whole number of pixels"}
+		}
+		*field.value = value
+	}
+	if background := r.FormValue("background"); background != "" {
+		parsed, err := imaging.ParseColor(background)
+		if err != nil {
+			return options, err
+		}
+		options.Background = parsed
+	}
+	options.Footer = strings.TrimSpace(r.FormValue("footer"))
+	return options, nil
+}
+
+// saveStrip renders each frame with the edits and overlay, lays them out
+// into one image and stores it with the frames linked to it. Strips have no
+// single original, so they can't be re-rendered.
+func (c *Controller) saveStrip(userID int, frameData []string, overlayName string, edits models.Edits, options imaging.StripOptions) (_ int, err error) {
+	if err := imaging.ValidateStrip(len(frameData), options); err != nil {
+		return 0, err
+	}
+	if err := imaging.ValidateEdits(edits); err != nil {
+		return 0, err
+	}
+
+	strip := &models.Image{UserID: userID, Overlay: internal.OverlaySlug(overlayName), Layout: options.Layout, Edits: edits}
+	frames, err := c.renderFrames(frameData, strip.Overlay, edits, 0)
+	if err != nil {
+		return 0, err
+	}
+	img, err := imaging.ComposeStrip(frames, options)
+	if err != nil {
+		return 0, err
+	}
+
+	// Files saved before a failure would never be linked to an image.
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, frame := range strip.Frames {
+			if err := c.Files.Delete(frame.FilePath); err != nil {
+				log.Printf("Error deleting %s: %v", frame.FilePath, err)
+			}
+		}
+		if strip.FilePath != "" {
+			c.removeFiles(strip.FilePath, strip.Renditions)
+		}
+	}()
+
+	for i, frame := range frames {
+		filePath, err := internal.SaveFrame(c.Files, frame)
+		if err != nil {
+			return 0, fmt.Errorf("saving frame: %w", err)
+		}
+		strip.Frames = append(strip.Frames, models.Frame{Position: i, FilePath: filePath})
+	}
+
+	strip.FilePath, err = internal.SaveImage(c.Files, img, imaging.FormatJPEG)
+	if err != nil {
+		return 0, fmt.Errorf("saving image: %w", err)
+	}
+	strip.Renditions, err = internal.SaveRenditions(c.Files, img, strip.FilePath)
+	if err != nil {
+		return 0, fmt.Errorf("saving renditions: %w", err)
+	}
+
+	if err := c.Images.Create(strip); err != nil {
+		return 0, err
+	}
+	return strip.ID, nil
+}
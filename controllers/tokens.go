@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
+)
+
+func (c *Controller) CreateAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(internal.UserIDKey).(int)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Token name is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.Users.GetByID(userID)
+	if err != nil {
+		http.Error(w, "Unable to load user data", http.StatusInternalServerError)
+		return
+	}
+
+	var scopes []string
+	for _, scope := range models.ScopesFor(user) {
+		for _, requested := range r.Form["scopes"] {
+			if requested == scope {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		http.Error(w, "Select at least one scope", http.StatusBadRequest)
+		return
+	}
+
+	raw, hash := internal.NewAPIToken()
+	token := models.APIToken{UserID: userID, Name: name, TokenHash: hash, Scopes: scopes}
+	if err := c.APITokens.Create(&token); err != nil {
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.ParseFiles("templates/api_token_created.html")
+	if err != nil {
+		http.Error(w, "Unable to load token page", http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, struct {
+		Token         models.APIToken
+		Secret        string
+		Authenticated bool
+		CSRFToken     string
+	}{
+		Token:         token,
+		Secret:        raw,
+		Authenticated: true,
+		CSRFToken:     internal.CSRFToken(r),
+	})
+}
+
+func (c *Controller) RevokeAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(internal.UserIDKey).(int)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokenID, err := strconv.Atoi(r.FormValue("token_id"))
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	err = c.APITokens.Delete(userID, tokenID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/store"
+	"photo-booth.com/internal/twofactor"
+)
+
+// twoFactorLoginTimeout is how long a correct password stays valid while the
+// user looks up their authentication code.
+const twoFactorLoginTimeout = 5 * time.Minute
+
+func (c *Controller) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := internal.Store.Get(r, "session")
+	userID, _ := session.Values["pending_user_id"].(int)
+	pendingSince, _ := session.Values["pending_since"].(int64)
+	if userID == 0 || time.Since(time.Unix(pendingSince, 0)) > twoFactorLoginTimeout {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		tmpl, err := template.ParseFiles("templates/login_2fa.html")
+		if err != nil {
+			http.Error(w, "Unable to load login page", http.StatusInternalServerError)
+			return
+		}
+		tmpl.Execute(w, struct {
+			Authenticated bool
+			CSRFToken     string
+		}{Authenticated: false, CSRFToken: internal.CSRFToken(r)})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		lockoutKey := "2fa:" + strconv.Itoa(userID)
+		if !allow(w, c.Limits.LoginIP, internal.ClientIP(r)) {
+			return
+		}
+		if locked := c.Limits.Lockout.Check(lockoutKey); locked > 0 {
+			tooManyRequests(w, locked)
+			return
+		}
+
+		ok, err := c.verifySecondFactor(userID, r.FormValue("code"))
+		if err != nil {
+			log.Printf("Error verifying second factor for user %d: %v", userID, err)
+			http.Error(w, "Unable to verify code", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			c.Limits.Lockout.Fail(lockoutKey)
+			http.Error(w, "Invalid authentication code", http.StatusUnauthorized)
+			return
+		}
+		c.Limits.Lockout.Reset(lockoutKey)
+
+		if err := signIn(w, r, userID); err != nil {
+			log.Printf("Error saving session: %v", err)
+			http.Error(w, "Unable to save session", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+	}
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code. TOTP codes can only be used once.
+func (c *Controller) verifySecondFactor(userID int, code string) (bool, error) {
+	tf, err := c.TwoFactor.Get(userID)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := twofactor.Validate(tf.Secret, code, time.Now()); ok {
+		err := c.TwoFactor.UseStep(userID, step)
+		if errors.Is(err, store.ErrInvalidToken) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	err = c.TwoFactor.UseRecoveryCode(userID, twofactor.HashRecoveryCode(code))
+	if errors.Is(err, store.ErrInvalidToken) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func signIn(w http.ResponseWriter, r *http.Request, userID int) error {
+	session, _ := internal.Store.Get(r, "session")
+	delete(session.Values, "pending_user_id")
+	delete(session.Values, "pending_since")
+	session.Values["authenticated"] = true
+	session.Values["user_id"] = userID
+	return session.Save(r, w)
+}
+
+func (c *Controller) TwoFactorSetupHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(internal.UserIDKey).(int)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := c.Users.GetByID(userID)
+	if err != nil {
+		http.Error(w, "Unable to load user data", http.StatusInternalServerError)
+		return
+	}
+
+	tf, err := c.TwoFactor.Get(userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Unable to load two-factor settings", http.StatusInternalServerError)
+		return
+	}
+	if tf != nil && tf.Enabled {
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		secret := ""
+		if tf != nil {
+			secret = tf.Secret
+		} else {
+			secret, err = twofactor.NewSecret(user.Username)
+			if err != nil {
+				http.Error(w, "Unable to generate secret", http.StatusInternalServerError)
+				return
+			}
+			if err := c.TwoFactor.SavePending(userID, secret); err != nil {
+				http.Error(w, "Unable to save secret", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		uri, err := twofactor.ProvisioningURI(secret, user.Username)
+		if err != nil {
+			http.Error(w, "Unable to generate provisioning URI", http.StatusInternalServerError)
+			return
+		}
+		qrCode, err := twofactor.QRCode(secret, user.Username)
+		if err != nil {
+			http.Error(w, "Unable to generate QR code", http.StatusInternalServerError)
+			return
+		}
+
+		tmpl, err := template.ParseFiles("templates/two_factor_setup.html")
+		if err != nil {
+			http.Error(w, "Unable to load two-factor setup page", http.StatusInternalServerError)
+			return
+		}
+		tmpl.Execute(w, struct {
+			Secret          string
+			ProvisioningURI string
+			QRCode          string
+			Authenticated   bool
+			CSRFToken       string
+		}{
+			Secret:          secret,
+			ProvisioningURI: uri,
+			QRCode:          qrCode,
+			Authenticated:   true,
+			CSRFToken:       internal.CSRFToken(r),
+		})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if tf == nil {
+			http.Redirect(w, r, "/settings/2fa", http.StatusSeeOther)
+			return
+		}
+
+		step, ok := twofactor.Validate(tf.Secret, r.FormValue("code"), time.Now())
+		if !ok || c.TwoFactor.UseStep(userID, step) != nil {
+			http.Error(w, "Invalid authentication code", http.StatusBadRequest)
+			return
+		}
+
+		codes := twofactor.NewRecoveryCodes()
+		hashes := make([]string, len(codes))
+		for i, code := range codes {
+			hashes[i] = twofactor.HashRecoveryCode(code)
+		}
+		if err := c.TwoFactor.Enable(userID, hashes); err != nil {
+			http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		tmpl, err := template.ParseFiles("templates/two_factor_recovery.html")
+		if err != nil {
+			http.Error(w, "Unable to load recovery codes page", http.StatusInternalServerError)
+			return
+		}
+		tmpl.Execute(w, struct {
+			RecoveryCodes []string
+			Authenticated bool
+			CSRFToken     string
+		}{
+			RecoveryCodes: codes,
+			Authenticated: true,
+			CSRFToken:     internal.CSRFToken(r),
+		})
+	}
+}
+
+func (c *Controller) DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(internal.UserIDKey).(int)
+	if !ok || userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// A code is asked for rather than the password, which accounts
+	// created through an OpenID Connect provider don't have.
+	lockoutKey := "2fa:" + strconv.Itoa(userID)
+	if locked := c.Limits.Lockout.Check(lockoutKey); locked > 0 {
+		tooManyRequests(w, locked)
+		return
+	}
+	ok, err := c.verifySecondFactor(userID, r.FormValue("code"))
+	if err != nil {
+		log.Printf("Error verifying second factor for user %d: %v", userID, err)
+		http.Error(w, "Unable to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		c.Limits.Lockout.Fail(lockoutKey)
+		http.Error(w, "Invalid authentication code", http.StatusUnauthorized)
+		return
+	}
+	c.Limits.Lockout.Reset(lockoutKey)
+
+	if err := c.TwoFactor.Disable(userID); err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
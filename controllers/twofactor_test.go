@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/ratelimit"
+	"photo-booth.com/internal/store"
+	"photo-booth.com/internal/twofactor"
+)
+
+// enableTwoFactor turns on two-factor authentication for the user and
+// returns the TOTP secret and one recovery code.
+func enableTwoFactor(t *testing.T, stores store.Stores, userID int) (string, string) {
+	t.Helper()
+	secret, err := twofactor.NewSecret("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCode := twofactor.NewRecoveryCodes()[0]
+	if err := stores.TwoFactor.SavePending(userID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.TwoFactor.Enable(userID, []string{twofactor.HashRecoveryCode(recoveryCode)}); err != nil {
+		t.Fatal(err)
+	}
+	return secret, recoveryCode
+}
+
+func currentCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// withCookie adds the session cookie w set, or else cookie, to r.
+func withCookie(r *http.Request, w *httptest.ResponseRecorder, cookie *http.Cookie) (*http.Request, *http.Cookie) {
+	for _, updated := range w.Result().Cookies() {
+		cookie = updated
+	}
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	return r, cookie
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	c, stores := newTestController(t)
+	c.Limits = ratelimit.NewAuth(ratelimit.NewMemoryStore())
+	internal.Store = internal.NewSessionStore(stores.Sessions, []byte("test-secret"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: string(hash), IsConfirmed: true}
+	if err := stores.Users.Create(alice); err != nil {
+		t.Fatal(err)
+	}
+	secret, recoveryCode := enableTwoFactor(t, stores, alice.ID)
+	totpCode := currentCode(t, secret)
+
+	// secondStep logs in with the password, then submits code to the
+	// second step and returns its response and the session it ends with.
+	secondStep := func(code string) (*httptest.ResponseRecorder, *http.Cookie) {
+		w := httptest.NewRecorder()
+		c.LoginHandler(w, postForm("/login", url.Values{"username": {"alice"}, "password": {"correct horse"}}))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login/2fa" {
+			t.Fatalf("login: status %d, location %q, want the second step", w.Code, w.Header().Get("Location"))
+		}
+		r, cookie := withCookie(postForm("/login/2fa", url.Values{"code": {code}}), w, nil)
+		w = httptest.NewRecorder()
+		c.LoginTwoFactorHandler(w, r)
+		return w, cookie
+	}
+	signedIn := func(w *httptest.ResponseRecorder, cookie *http.Cookie) bool {
+		r, _ := withCookie(httptest.NewRequest(http.MethodGet, "/", nil), w, cookie)
+		session, _ := internal.Store.Get(r, "session")
+		userID, _ := session.Values["user_id"].(int)
+		return session.Values["authenticated"] == true && userID == alice.ID
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want int
+	}{
+		{"wrong code", "000000", http.StatusUnauthorized},
+		{"current code", totpCode, http.StatusSeeOther},
+		{"replayed code", totpCode, http.StatusUnauthorized},
+		{"recovery code", recoveryCode, http.StatusSeeOther},
+		{"used recovery code", recoveryCode, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w, cookie := secondStep(tt.code)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if got := signedIn(w, cookie); got != (tt.want == http.StatusSeeOther) {
+			t.Errorf("%s: signed in = %v", tt.name, got)
+		}
+	}
+
+	// The second step can't be reached without the password.
+	w := httptest.NewRecorder()
+	c.LoginTwoFactorHandler(w, postForm("/login/2fa", url.Values{"code": {currentCode(t, secret)}}))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+		t.Errorf("without a password: status %d, location %q, want a redirect to /login", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestDisableTwoFactorHandler(t *testing.T) {
+	c, stores := newTestController(t)
+	c.Limits = ratelimit.NewAuth(ratelimit.NewMemoryStore())
+	// Accounts created through an OpenID Connect provider have no password.
+	alice := &models.User{Username: "alice", Email: "alice@example.com", IsConfirmed: true}
+	if err := stores.Users.Create(alice); err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := enableTwoFactor(t, stores, alice.ID)
+
+	disable := func(values url.Values) int {
+		w := httptest.NewRecorder()
+		c.DisableTwoFactorHandler(w, asUser(postForm("/settings/2fa/disable", values), alice.ID))
+		return w.Code
+	}
+	enabled := func() bool {
+		_, err := stores.TwoFactor.Get(alice.ID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	if code := disable(url.Values{"code": {"000000"}}); code != http.StatusUnauthorized || !enabled() {
+		t.Errorf("wrong code: status %d, enabled %v, want 401 and still enabled", code, enabled())
+	}
+	if code := disable(url.Values{"current_password": {""}}); code != http.StatusUnauthorized || !enabled() {
+		t.Errorf("empty password: status %d, enabled %v, want 401 and still enabled", code, enabled())
+	}
+	if code := disable(url.Values{"code": {currentCode(t, secret)}}); code != http.StatusSeeOther || enabled() {
+		t.Errorf("current code: status %d, enabled %v, want a redirect and disabled", code, enabled())
+	}
+
+	// Guessing codes runs into the same lockout as the login step.
+	secret, _ = enableTwoFactor(t, stores, alice.ID)
+	for i := 0; i < c.Limits.Lockout.Threshold; i++ {
+		disable(url.Values{"code": {"000000"}})
+	}
+	if code := disable(url.Values{"code": {currentCode(t, secret)}}); code != http.StatusTooManyRequests || !enabled() {
+		t.Errorf("while locked: status %d, enabled %v, want 429 and still enabled", code, enabled())
+	}
+}
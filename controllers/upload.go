@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"photo-booth.com/internal"
+	"photo-booth.com/internal/imaging"
+)
+
+// UploadHandler stores an image file sent as the "file" field of a multipart
+// form, with an optional overlay.
+func (c *Controller) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := r.Context().Value(internal.UserIDKey).(int)
+	if _, err := c.saveUpload(r, userID); err != nil {
+		status, message := imageError(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	http.Redirect(w, r, "/gallery", http.StatusSeeOther)
+}
+
+// saveUpload validates the uploaded file by its content, never by its name or
+// declared type, and stores a re-encoded copy.
+func (c *Controller) saveUpload(r *http.Request, userID int) (int, error) {
+	edits, err := parseEdits(r)
+	if err != nil {
+		return 0, err
+	}
+	upload, err := readUpload(r)
+	if err != nil {
+		return 0, err
+	}
+	return c.saveImage(userID, upload, r.FormValue("overlay"), edits)
+}
+
+// readUpload decodes the "file" field of a multipart form.
+func readUpload(r *http.Request) (*imaging.Upload, error) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, errUploadTooLarge
+		}
+		return nil, errNoImageData
+	}
+	defer file.Close()
+
+	if header.Size > internal.MaxUploadSize {
+		return nil, errUploadTooLarge
+	}
+
+	return imaging.DecodeUpload(file)
+}
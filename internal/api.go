@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// APIPrefix is where the versioned JSON API is mounted.
+const APIPrefix = "/api/v1/"
+
+// APIError is the body of every error response from the JSON API:
+// {"error": {"status": 404, "code": "not_found", "message": "..."}}.
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v", err)
+	}
+}
+
+func WriteAPIError(w http.ResponseWriter, status int, message string) {
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	WriteJSON(w, status, struct {
+		Error APIError `json:"error"`
+	}{APIError{Status: status, Code: code, Message: message}})
+}
+
+// Error is http.Error for middleware that also sits in front of the API, where
+// clients expect the JSON error envelope.
+func Error(w http.ResponseWriter, r *http.Request, message string, status int) {
+	if strings.HasPrefix(r.URL.Path, APIPrefix) {
+		WriteAPIError(w, status, message)
+		return
+	}
+	http.Error(w, message, status)
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ClientIP returns the address of the client. X-Forwarded-For is only
+// honoured when TRUST_PROXY=true, since clients can set it freely otherwise.
+// Proxies append to the header, so the address is read from the right,
+// skipping one entry for every proxy in front of the application
+// (TRUST_PROXY_HOPS, 1 by default). Anything further left came from the
+// client.
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		var entries []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, entry := range strings.Split(header, ",") {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					entries = append(entries, entry)
+				}
+			}
+		}
+		if len(entries) > 0 {
+			hops, err := strconv.Atoi(os.Getenv("TRUST_PROXY_HOPS"))
+			if err != nil || hops < 1 {
+				hops = 1
+			}
+			if hops > len(entries) {
+				hops = len(entries)
+			}
+			return entries[len(entries)-hops]
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     string
+		hops      string
+		forwarded []string
+		want      string
+	}{
+		{name: "untrusted header", forwarded: []string{"203.0.113.9"}, want: "192.0.2.1"},
+		{name: "no header", trust: "true", want: "192.0.2.1"},
+		{name: "single proxy", trust: "true", forwarded: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "spoofed entry", trust: "true", forwarded: []string{"10.9.9.9, 203.0.113.9"}, want: "203.0.113.9"},
+		{name: "repeated header", trust: "true", forwarded: []string{"10.9.9.9", "203.0.113.9"}, want: "203.0.113.9"},
+		{name: "two hops", trust: "true", hops: "2", forwarded: []string{"10.9.9.9, 203.0.113.9, 10.0.0.2"}, want: "203.0.113.9"},
+		{name: "blank entries", trust: "true", forwarded: []string{" , 203.0.113.9 ,"}, want: "203.0.113.9"},
+		{name: "invalid hops", trust: "true", hops: "none", forwarded: []string{"10.9.9.9, 203.0.113.9"}, want: "203.0.113.9"},
+		{name: "more hops than entries", trust: "true", hops: "3", forwarded: []string{"203.0.113.9, 10.0.0.2"}, want: "203.0.113.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY", tt.trust)
+			t.Setenv("TRUST_PROXY_HOPS", tt.hops)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:5000"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+)
+
+const CSRFTokenKey contextKey = "csrf_token"
+
+const CSRFFieldName = "csrf_token"
+const CSRFHeaderName = "X-CSRF-Token"
+
+// csrfState carries the session's CSRF token through a request. Sessions
+// are only created once a page asks for a token, so requests that render no
+// form don't leave a stored session behind.
+type csrfState struct {
+	w     http.ResponseWriter
+	r     *http.Request
+	token string
+}
+
+func CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Browsers never attach bearer tokens on their own, so these requests
+		// can't be forged cross-site.
+		if APIToken(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, _ := Store.Get(r, "session")
+		token, _ := session.Values["csrf_token"].(string)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		default:
+			submitted := r.Header.Get(CSRFHeaderName)
+			if submitted == "" {
+				var tooLarge *http.MaxBytesError
+				if err := r.ParseMultipartForm(32 << 20); errors.As(err, &tooLarge) {
+					Error(w, r, "Request body is too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				submitted = r.FormValue(CSRFFieldName)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+				Error(w, r, "Invalid or missing CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+
+		state := &csrfState{w: w, token: token}
+		r = r.WithContext(context.WithValue(r.Context(), CSRFTokenKey, state))
+		state.r = r
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSRFToken returns the session's CSRF token, creating it and saving the
+// session on first use. It must be called before the response is written.
+func CSRFToken(r *http.Request) string {
+	state, _ := r.Context().Value(CSRFTokenKey).(*csrfState)
+	if state == nil {
+		return ""
+	}
+	if state.token == "" {
+		session, _ := Store.Get(state.r, "session")
+		token := newCSRFToken()
+		session.Values["csrf_token"] = token
+		if err := session.Save(state.r, state.w); err != nil {
+			log.Printf("Error saving session: %v", err)
+			return ""
+		}
+		state.token = token
+	}
+	return state.token
+}
+
+func newCSRFToken() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
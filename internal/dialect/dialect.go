@@ -0,0 +1,99 @@
+package dialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Dialect struct {
+	Name   string
+	Driver string
+}
+
+var (
+	SQLite   = Dialect{Name: "sqlite", Driver: "sqlite3"}
+	Postgres = Dialect{Name: "postgres", Driver: "postgres"}
+)
+
+const DefaultDatabaseURL = "sqlite://data/photo-booth.db"
+
+// Parse maps DATABASE_URL to a dialect and the data source name understood by
+// its driver. Accepted forms are sqlite://<path>, file:<path> and
+// postgres://... (or postgresql://...).
+func Parse(databaseURL string) (Dialect, string, error) {
+	if databaseURL == "" {
+		databaseURL = DefaultDatabaseURL
+	}
+
+	switch {
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		return SQLite, strings.TrimPrefix(databaseURL, "sqlite://"), nil
+	case strings.HasPrefix(databaseURL, "sqlite3://"):
+		return SQLite, strings.TrimPrefix(databaseURL, "sqlite3://"), nil
+	case strings.HasPrefix(databaseURL, "file:"):
+		return SQLite, databaseURL, nil
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		return Postgres, databaseURL, nil
+	default:
+		return Dialect{}, "", fmt.Errorf("unsupported DATABASE_URL %q", databaseURL)
+	}
+}
+
+// Rebind rewrites ? placeholders into the dialect's native form. Question
+// marks inside quoted literals are left untouched.
+func (d Dialect) Rebind(query string) string {
+	if d.Name != Postgres.Name {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// Timestamp wraps a timestamp column or placeholder so that it compares and
+// sorts by instant. SQLite keeps timestamps as text in whatever format wrote
+// them, CURRENT_TIMESTAMP's included, so there they are compared as Julian
+// day numbers, which are precise to the millisecond.
+func (d Dialect) Timestamp(expr string) string {
+	if d.Name == Postgres.Name {
+		return expr
+	}
+	return "julianday(" + expr + ")"
+}
+
+func (d Dialect) IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	message := err.Error()
+	switch d.Name {
+	case Postgres.Name:
+		return strings.Contains(message, "duplicate key value violates unique constraint")
+	default:
+		return strings.Contains(message, "UNIQUE constraint failed")
+	}
+}
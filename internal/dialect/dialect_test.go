@@ -0,0 +1,72 @@
+package dialect
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		url     string
+		dialect Dialect
+		dsn     string
+	}{
+		{"", SQLite, "data/photo-booth.db"},
+		{"sqlite://data/test.db", SQLite, "data/test.db"},
+		{"sqlite3:///tmp/test.db", SQLite, "/tmp/test.db"},
+		{"file:test.db?cache=shared", SQLite, "file:test.db?cache=shared"},
+		{"postgres://user@localhost/booth", Postgres, "postgres://user@localhost/booth"},
+		{"postgresql://localhost/booth?sslmode=disable", Postgres, "postgresql://localhost/booth?sslmode=disable"},
+	}
+	for _, tt := range tests {
+		d, dsn, err := Parse(tt.url)
+		if err != nil || d != tt.dialect || dsn != tt.dsn {
+			t.Errorf("Parse(%q) = %v, %q, %v; want %v, %q", tt.url, d.Name, dsn, err, tt.dialect.Name, tt.dsn)
+		}
+	}
+
+	if _, _, err := Parse("mysql://localhost/booth"); err == nil {
+		t.Error("Parse accepted a mysql URL")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query    string
+		postgres string
+	}{
+		{"SELECT id FROM users WHERE id = ?", "SELECT id FROM users WHERE id = $1"},
+		{"INSERT INTO t (a, b, c) VALUES (?, ?, ?) RETURNING id", "INSERT INTO t (a, b, c) VALUES ($1, $2, $3) RETURNING id"},
+		{"SELECT '?' AS q, \"a?\" FROM t WHERE x = ?", "SELECT '?' AS q, \"a?\" FROM t WHERE x = $1"},
+		{"SELECT 1", "SELECT 1"},
+	}
+	for _, tt := range tests {
+		if got := Postgres.Rebind(tt.query); got != tt.postgres {
+			t.Errorf("Postgres.Rebind(%q) = %q, want %q", tt.query, got, tt.postgres)
+		}
+		if got := SQLite.Rebind(tt.query); got != tt.query {
+			t.Errorf("SQLite.Rebind(%q) = %q, want it unchanged", tt.query, got)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	if !SQLite.IsUniqueViolation(errors.New("UNIQUE constraint failed: users.username")) {
+		t.Error("SQLite unique violation not recognised")
+	}
+	if !Postgres.IsUniqueViolation(errors.New(`pq: duplicate key value violates unique constraint "users_username_key"`)) {
+		t.Error("Postgres unique violation not recognised")
+	}
+	if Postgres.IsUniqueViolation(nil) || SQLite.IsUniqueViolation(errors.New("database is locked")) {
+		t.Error("other errors reported as unique violations")
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	if got := SQLite.Timestamp("images.created_at"); got != "julianday(images.created_at)" {
+		t.Errorf("SQLite.Timestamp = %q", got)
+	}
+	if got := Postgres.Timestamp("?"); got != "?" {
+		t.Errorf("Postgres.Timestamp = %q", got)
+	}
+}
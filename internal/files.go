@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/storage"
+	"photo-booth.com/internal/utils"
+)
+
+var renditionSizes = []struct {
+	name  string
+	width int
+}{
+	{models.RenditionThumbnail, 320},
+	{models.RenditionMedium, 960},
+}
+
+// MaxUploadSize is the largest image file accepted from a multipart upload.
+const MaxUploadSize = 10 << 20
+
+// MaxRequestSize caps every request body; it leaves room for the other form
+// fields sent along with an upload.
+const MaxRequestSize = MaxUploadSize + 1<<20
+
+// DecodeImageFromBase64 decodes a data URL, validating it like an uploaded
+// file.
+func DecodeImageFromBase64(data string) (*imaging.Upload, error) {
+	parts := strings.Split(data, ",")
+	if len(parts) != 2 {
+		return nil, imaging.ErrInvalidImage
+	}
+	decoded, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, imaging.ErrInvalidImage
+	}
+
+	return imaging.DecodeUpload(bytes.NewReader(decoded))
+}
+
+// SaveImage re-encodes img so nothing but pixels from the original file is
+// stored. JPEGs stay JPEGs; the lossless formats are saved as PNG.
+func SaveImage(files storage.Storage, img image.Image, format string) (string, error) {
+	return saveEncoded(files, "photo", img, format)
+}
+
+// SaveOriginal keeps a capture before its overlay and edits are applied, so
+// the image can be rendered again later. It is encoded like SaveImage.
+func SaveOriginal(files storage.Storage, img image.Image, format string) (string, error) {
+	return saveEncoded(files, "originals/photo", img, format)
+}
+
+// SaveFrame stores one rendered frame of a photo strip as a JPEG.
+func SaveFrame(files storage.Storage, img image.Image) (string, error) {
+	return saveEncoded(files, "frames/photo", img, imaging.FormatJPEG)
+}
+
+// SaveAnimation stores an encoded GIF animation.
+func SaveAnimation(files storage.Storage, data []byte) (string, error) {
+	key := newKey("photo", "gif")
+	if err := files.Put(key, bytes.NewReader(data), "image/gif"); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// SavePoster stores the still frame shown for an animation in the gallery
+// grid, next to the animation saved under animationKey.
+func SavePoster(files storage.Storage, img image.Image, animationKey string) (models.Rendition, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return models.Rendition{}, err
+	}
+
+	key := strings.TrimSuffix(animationKey, path.Ext(animationKey)) + "_" + models.RenditionPoster + ".jpg"
+	if err := files.Put(key, &buf, "image/jpeg"); err != nil {
+		return models.Rendition{}, err
+	}
+	return models.Rendition{Name: models.RenditionPoster, FilePath: key, Width: img.Bounds().Dx()}, nil
+}
+
+// LoadOriginal decodes an original saved by SaveOriginal and returns the
+// format to render it in.
+func LoadOriginal(files storage.Storage, key string) (image.Image, string, error) {
+	file, err := files.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", err
+	}
+	img, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	format := imaging.FormatPNG
+	if path.Ext(key) == ".jpg" {
+		format = imaging.FormatJPEG
+	}
+	return img, format, nil
+}
+
+func saveEncoded(files storage.Storage, prefix string, img image.Image, format string) (string, error) {
+	var buf bytes.Buffer
+	ext, contentType := "png", "image/png"
+	if format == imaging.FormatJPEG {
+		ext, contentType = "jpg", "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92}); err != nil {
+			return "", err
+		}
+	} else if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	key := newKey(prefix, ext)
+	if err := files.Put(key, &buf, contentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// newKey returns a storage key with a random suffix, so keys can't be
+// guessed from the time an image was saved.
+func newKey(prefix, ext string) string {
+	return fmt.Sprintf("%s_%s.%s", prefix, utils.GenerateToken(), ext)
+}
+
+func SaveRenditions(files storage.Storage, img image.Image, originalKey string) ([]models.Rendition, error) {
+	width := img.Bounds().Dx()
+	base := strings.TrimSuffix(originalKey, path.Ext(originalKey))
+
+	var renditions []models.Rendition
+	for _, size := range renditionSizes {
+		if size.width >= width {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, imaging.Resize(img, size.width), &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s_%s.jpg", base, size.name)
+		if err := files.Put(key, &buf, "image/jpeg"); err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, models.Rendition{Name: size.name, FilePath: key, Width: size.width})
+	}
+
+	renditions = append(renditions, models.Rendition{Name: models.RenditionOriginal, FilePath: originalKey, Width: width})
+	return renditions, nil
+}
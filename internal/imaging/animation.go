@@ -0,0 +1,219 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"sort"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	LayoutAnimation = "animation"
+	LayoutBoomerang = "boomerang"
+)
+
+const (
+	MinBurstFrames = 2
+	MaxBurstFrames = 20
+	// MinFrameDelay and MaxFrameDelay bound the time between frames, in
+	// milliseconds.
+	MinFrameDelay     = 20
+	MaxFrameDelay     = 1000
+	DefaultFrameDelay = 100
+	// MaxAnimationSize caps the encoded GIF. Animations that come out larger
+	// are encoded again at a smaller size, down to minAnimationWidth.
+	MaxAnimationSize = 4 << 20
+	// BurstFrameSize bounds both sides of burst frames as they are decoded.
+	// It leaves room for crops and rotation before EncodeAnimation scales
+	// the frames down to animationWidth.
+	BurstFrameSize = 2 * animationWidth
+
+	animationWidth    = 480
+	minAnimationWidth = 160
+	paletteSize       = 256
+	paletteSamples    = 1 << 16
+)
+
+var ErrAnimationTooLarge = errors.New("animation exceeds size limit")
+
+// AnimationOptions describe how burst frames are played back. A boomerang
+// plays the frames forwards and then backwards.
+type AnimationOptions struct {
+	Delay     int
+	Boomerang bool
+}
+
+// InvalidAnimationError reports why an animation was rejected.
+type InvalidAnimationError struct {
+	Reason string
+}
+
+func (e *InvalidAnimationError) Error() string {
+	return "invalid animation: " + e.Reason
+}
+
+// ValidateAnimation checks the options and number of frames before anything
+// is decoded or rendered.
+func ValidateAnimation(frames int, options AnimationOptions) error {
+	if frames < MinBurstFrames || frames > MaxBurstFrames {
+		return &InvalidAnimationError{Reason: fmt.Sprintf("an animation needs %d to %d frames", MinBurstFrames, MaxBurstFrames)}
+	}
+	if options.Delay < MinFrameDelay || options.Delay > MaxFrameDelay {
+		return &InvalidAnimationError{Reason: fmt.Sprintf("delay must be between %d and %d milliseconds", MinFrameDelay, MaxFrameDelay)}
+	}
+	return nil
+}
+
+// EncodeAnimation encodes validated frames as a looping GIF with one palette
+// shared by all frames, and returns it with its width. Every frame is drawn
+// at the shape of the first one.
+func EncodeAnimation(frames []image.Image, options AnimationOptions) ([]byte, int, error) {
+	order := make([]int, 0, 2*len(frames))
+	for i := range frames {
+		order = append(order, i)
+	}
+	if options.Boomerang {
+		for i := len(frames) - 2; i > 0; i-- {
+			order = append(order, i)
+		}
+	}
+	// GIF delays are in hundredths of a second.
+	delay := (options.Delay + 5) / 10
+
+	first := frames[0].Bounds()
+	width := first.Dx()
+	if width > animationWidth {
+		width = animationWidth
+	}
+	for {
+		height := first.Dy() * width / first.Dx()
+		if height < 1 {
+			height = 1
+		}
+
+		scaled := make([]*image.RGBA, len(frames))
+		for i, frame := range frames {
+			scaled[i] = image.NewRGBA(image.Rect(0, 0, width, height))
+			xdraw.CatmullRom.Scale(scaled[i], scaled[i].Bounds(), frame, coverRect(frame.Bounds(), width, height), draw.Src, nil)
+		}
+
+		palette := quantize(scaled, paletteSize)
+		paletted := make([]*image.Paletted, len(scaled))
+		for i, frame := range scaled {
+			paletted[i] = image.NewPaletted(frame.Bounds(), palette)
+			draw.FloydSteinberg.Draw(paletted[i], frame.Bounds(), frame, image.Point{})
+		}
+
+		anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: width, Height: height}}
+		for _, i := range order {
+			anim.Image = append(anim.Image, paletted[i])
+			anim.Delay = append(anim.Delay, delay)
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			return nil, 0, err
+		}
+		if buf.Len() <= MaxAnimationSize {
+			return buf.Bytes(), width, nil
+		}
+		if width <= minAnimationWidth {
+			return nil, 0, ErrAnimationTooLarge
+		}
+		width = width * 3 / 4
+		if width < minAnimationWidth {
+			width = minAnimationWidth
+		}
+	}
+}
+
+// quantize picks a palette of up to size colors for all frames by median cut
+// over a sample of their pixels.
+func quantize(frames []*image.RGBA, size int) color.Palette {
+	total := 0
+	for _, frame := range frames {
+		total += len(frame.Pix) / 4
+	}
+	step := total/paletteSamples + 1
+
+	samples := make([][3]uint8, 0, total/step+1)
+	n := 0
+	for _, frame := range frames {
+		for i := 0; i < len(frame.Pix); i += 4 {
+			if n%step == 0 {
+				samples = append(samples, [3]uint8{frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2]})
+			}
+			n++
+		}
+	}
+
+	boxes := [][][3]uint8{samples}
+	for len(boxes) < size {
+		// Split the box with the widest range in any channel at its median.
+		best, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, s := widestChannel(box); s > spread {
+				best, channel, spread = i, c, s
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(a, b int) bool { return box[a][channel] < box[b][channel] })
+		boxes[best] = box[:len(box)/2]
+		boxes = append(boxes, box[len(box)/2:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var sum [3]int
+		for _, c := range box {
+			sum[0] += int(c[0])
+			sum[1] += int(c[1])
+			sum[2] += int(c[2])
+		}
+		palette = append(palette, color.RGBA{
+			R: uint8(sum[0] / len(box)),
+			G: uint8(sum[1] / len(box)),
+			B: uint8(sum[2] / len(box)),
+			A: 255,
+		})
+	}
+	return palette
+}
+
+// widestChannel returns the channel whose values spread the most in box, and
+// that spread.
+func widestChannel(box [][3]uint8) (int, int) {
+	lo := [3]uint8{255, 255, 255}
+	var hi [3]uint8
+	for _, c := range box {
+		for i := range c {
+			if c[i] < lo[i] {
+				lo[i] = c[i]
+			}
+			if c[i] > hi[i] {
+				hi[i] = c[i]
+			}
+		}
+	}
+
+	channel, spread := 0, 0
+	for i := range lo {
+		if s := int(hi[i]) - int(lo[i]); s > spread {
+			channel, spread = i, s
+		}
+	}
+	return channel, spread
+}
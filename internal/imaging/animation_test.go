@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+func TestValidateAnimation(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames int
+		delay  int
+		valid  bool
+	}{
+		{"fewest frames", MinBurstFrames, DefaultFrameDelay, true},
+		{"most frames", MaxBurstFrames, DefaultFrameDelay, true},
+		{"one frame", MinBurstFrames - 1, DefaultFrameDelay, false},
+		{"too many frames", MaxBurstFrames + 1, DefaultFrameDelay, false},
+		{"shortest delay", 5, MinFrameDelay, true},
+		{"longest delay", 5, MaxFrameDelay, true},
+		{"delay too short", 5, MinFrameDelay - 1, false},
+		{"delay too long", 5, MaxFrameDelay + 1, false},
+	}
+	for _, tt := range tests {
+		err := ValidateAnimation(tt.frames, AnimationOptions{Delay: tt.delay})
+		var invalid *InvalidAnimationError
+		if tt.valid && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.As(err, &invalid) {
+			t.Errorf("%s: err = %v, want an InvalidAnimationError", tt.name, err)
+		}
+	}
+}
+
+func TestEncodeAnimation(t *testing.T) {
+	frames := func(n, width, height int) []image.Image {
+		images := make([]image.Image, n)
+		for i := range images {
+			frame := image.NewRGBA(image.Rect(0, 0, width, height))
+			draw.Draw(frame, frame.Bounds(), image.NewUniform(color.RGBA{R: uint8(40 * i), G: 100, B: 200, A: 255}), image.Point{}, draw.Src)
+			images[i] = frame
+		}
+		return images
+	}
+
+	tests := []struct {
+		name          string
+		frames        []image.Image
+		options       AnimationOptions
+		width, height int
+		count         int
+	}{
+		{"small frames", frames(3, 120, 90), AnimationOptions{Delay: 80}, 120, 90, 3},
+		{"large frames", frames(3, 1280, 960), AnimationOptions{Delay: 100}, animationWidth, 360, 3},
+		{"boomerang", frames(4, 200, 100), AnimationOptions{Delay: 100, Boomerang: true}, 200, 100, 6},
+		{"two frame boomerang", frames(2, 200, 100), AnimationOptions{Delay: 100, Boomerang: true}, 200, 100, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, width, err := EncodeAnimation(tt.frames, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) > MaxAnimationSize {
+				t.Errorf("%d bytes, over the size limit", len(data))
+			}
+			anim, err := gif.DecodeAll(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if width != tt.width || anim.Config.Width != tt.width || anim.Config.Height != tt.height {
+				t.Errorf("%dx%d (width %d), want %dx%d", anim.Config.Width, anim.Config.Height, width, tt.width, tt.height)
+			}
+			if len(anim.Image) != tt.count {
+				t.Errorf("%d frames, want %d", len(anim.Image), tt.count)
+			}
+			for _, delay := range anim.Delay {
+				if delay != (tt.options.Delay+5)/10 {
+					t.Errorf("delay %d, want %d hundredths", delay, (tt.options.Delay+5)/10)
+					break
+				}
+			}
+		})
+	}
+}
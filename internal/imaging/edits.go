@@ -0,0 +1,222 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"photo-booth.com/internal/models"
+)
+
+const (
+	FilterGrayscale    = "grayscale"
+	FilterSepia        = "sepia"
+	FilterVintage      = "vintage"
+	FilterHighContrast = "high-contrast"
+	FilterVignette     = "vignette"
+)
+
+var Filters = []string{FilterGrayscale, FilterSepia, FilterVintage, FilterHighContrast, FilterVignette}
+
+// InvalidEditsError reports why edits were rejected.
+type InvalidEditsError struct {
+	Reason string
+}
+
+func (e *InvalidEditsError) Error() string {
+	return "invalid edits: " + e.Reason
+}
+
+func invalidEdits(format string, args ...any) error {
+	return &InvalidEditsError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// ValidateEdits checks edits before anything is rendered.
+func ValidateEdits(edits models.Edits) error {
+	if edits.Filter != "" {
+		known := false
+		for _, filter := range Filters {
+			known = known || filter == edits.Filter
+		}
+		if !known {
+			return invalidEdits("unknown filter %q", edits.Filter)
+		}
+	}
+
+	for _, adjustment := range []struct {
+		name  string
+		value float64
+	}{
+		{"brightness", edits.Brightness},
+		{"contrast", edits.Contrast},
+		{"saturation", edits.Saturation},
+	} {
+		if math.IsNaN(adjustment.value) || adjustment.value < -1 || adjustment.value > 1 {
+			return invalidEdits("%s must be between -1 and 1", adjustment.name)
+		}
+	}
+
+	switch edits.Rotate {
+	case 0, 90, 180, 270:
+	default:
+		return invalidEdits("rotate must be 0, 90, 180 or 270")
+	}
+
+	if crop := edits.Crop; crop != nil {
+		for _, value := range []float64{crop.X, crop.Y, crop.Width, crop.Height} {
+			if math.IsNaN(value) || value < 0 || value > 1 {
+				return invalidEdits("crop values must be between 0 and 1")
+			}
+		}
+		if crop.Width == 0 || crop.Height == 0 || crop.X+crop.Width > 1.0001 || crop.Y+crop.Height > 1.0001 {
+			return invalidEdits("crop must be a non-empty area inside the photo")
+		}
+	}
+
+	return validateLayers(edits.Layers)
+}
+
+// ApplyEdits renders validated edits: the photo is rotated, then cropped,
+// then adjusted, and the filter comes last. Layers are drawn separately by
+// ApplyLayers, after the overlay.
+func ApplyEdits(img image.Image, edits models.Edits) image.Image {
+	edits.Layers = nil
+	if edits.IsZero() {
+		return img
+	}
+
+	switch edits.Rotate {
+	case 90:
+		img = Orient(img, 6)
+	case 180:
+		img = Orient(img, 3)
+	case 270:
+		img = Orient(img, 8)
+	}
+
+	dst := toNRGBA(img, cropRect(img.Bounds(), edits.Crop))
+
+	if edits.Brightness != 0 || edits.Contrast != 0 || edits.Saturation != 0 {
+		brightness := edits.Brightness * 255
+		contrast := 1 + edits.Contrast
+		saturation := 1 + edits.Saturation
+		mapPixels(dst, func(x, y int, r, g, b float64) (float64, float64, float64) {
+			r, g, b = r+brightness, g+brightness, b+brightness
+			r, g, b = (r-128)*contrast+128, (g-128)*contrast+128, (b-128)*contrast+128
+			gray := luma(r, g, b)
+			return gray + (r-gray)*saturation, gray + (g-gray)*saturation, gray + (b-gray)*saturation
+		})
+	}
+
+	switch edits.Filter {
+	case FilterGrayscale:
+		mapPixels(dst, func(x, y int, r, g, b float64) (float64, float64, float64) {
+			gray := luma(r, g, b)
+			return gray, gray, gray
+		})
+	case FilterSepia:
+		mapPixels(dst, func(x, y int, r, g, b float64) (float64, float64, float64) {
+			return sepia(r, g, b)
+		})
+	case FilterVintage:
+		// Half-strength sepia, warmed up, with faded blacks and soft corners.
+		vignette := vignetter(dst.Bounds(), 0.35)
+		mapPixels(dst, func(x, y int, r, g, b float64) (float64, float64, float64) {
+			sr, sg, sb := sepia(r, g, b)
+			r, g, b = (r+sr)/2*1.05, (g+sg)/2, (b+sb)/2*0.9
+			r, g, b = r*0.85+24, g*0.85+24, b*0.85+24
+			return vignette(x, y, r, g, b)
+		})
+	case FilterHighContrast:
+		mapPixels(dst, func(x, y int, r, g, b float64) (float64, float64, float64) {
+			r, g, b = (r-128)*1.6+128, (g-128)*1.6+128, (b-128)*1.6+128
+			gray := luma(r, g, b)
+			return gray + (r-gray)*1.2, gray + (g-gray)*1.2, gray + (b-gray)*1.2
+		})
+	case FilterVignette:
+		mapPixels(dst, vignetter(dst.Bounds(), 0.6))
+	}
+
+	return dst
+}
+
+// cropRect turns a fractional crop into pixels, keeping at least one pixel.
+func cropRect(bounds image.Rectangle, crop *models.Crop) image.Rectangle {
+	if crop == nil {
+		return bounds
+	}
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	x0, x1 := cropSpan(bounds.Min.X, bounds.Max.X, crop.X*w, (crop.X+crop.Width)*w)
+	y0, y1 := cropSpan(bounds.Min.Y, bounds.Max.Y, crop.Y*h, (crop.Y+crop.Height)*h)
+	return image.Rect(x0, y0, x1, y1)
+}
+
+// cropSpan turns offsets from lo into pixel coordinates, keeping the span
+// inside [lo, hi) and at least one pixel long.
+func cropSpan(lo, hi int, from, to float64) (int, int) {
+	start := lo + int(math.Round(from))
+	end := lo + int(math.Round(to))
+	if end > hi {
+		end = hi
+	}
+	if start > hi-1 {
+		start = hi - 1
+	}
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// toNRGBA copies the part of img inside rect to a new image at the origin.
+// Working on non-premultiplied colors keeps the adjustments correct for
+// translucent pixels.
+func toNRGBA(img image.Image, rect image.Rectangle) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+func mapPixels(img *image.NRGBA, f func(x, y int, r, g, b float64) (float64, float64, float64)) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+3 : i+3]
+			r, g, b := f(x, y, float64(p[0]), float64(p[1]), float64(p[2]))
+			p[0], p[1], p[2] = clamp(r), clamp(g), clamp(b)
+		}
+	}
+}
+
+// vignetter darkens pixels by up to strength towards the corners.
+func vignetter(bounds image.Rectangle, strength float64) func(x, y int, r, g, b float64) (float64, float64, float64) {
+	cx, cy := float64(bounds.Min.X+bounds.Max.X)/2, float64(bounds.Min.Y+bounds.Max.Y)/2
+	maxDist := cx*cx + cy*cy
+	return func(x, y int, r, g, b float64) (float64, float64, float64) {
+		dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+		factor := 1 - strength*(dx*dx+dy*dy)/maxDist
+		return r * factor, g * factor, b * factor
+	}
+}
+
+func luma(r, g, b float64) float64 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
+
+func sepia(r, g, b float64) (float64, float64, float64) {
+	return 0.393*r + 0.769*g + 0.189*b,
+		0.349*r + 0.686*g + 0.168*b,
+		0.272*r + 0.534*g + 0.131*b
+}
+
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,65 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"math"
+	"testing"
+
+	"photo-booth.com/internal/models"
+)
+
+func TestValidateEdits(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits models.Edits
+		valid bool
+	}{
+		{"no edits", models.Edits{}, true},
+		{"filter", models.Edits{Filter: FilterSepia}, true},
+		{"unknown filter", models.Edits{Filter: "posterize"}, false},
+		{"full adjustments", models.Edits{Brightness: -1, Contrast: 1, Saturation: 0.5}, true},
+		{"brightness too high", models.Edits{Brightness: 1.01}, false},
+		{"contrast too low", models.Edits{Contrast: -1.01}, false},
+		{"saturation NaN", models.Edits{Saturation: math.NaN()}, false},
+		{"rotate 270", models.Edits{Rotate: 270}, true},
+		{"rotate 45", models.Edits{Rotate: 45}, false},
+		{"crop", models.Edits{Crop: &models.Crop{X: 0.25, Y: 0, Width: 0.75, Height: 1}}, true},
+		{"empty crop", models.Edits{Crop: &models.Crop{X: 0.5, Y: 0.5}}, false},
+		{"crop outside the photo", models.Edits{Crop: &models.Crop{X: 0.5, Width: 0.75, Height: 1}}, false},
+		{"negative crop", models.Edits{Crop: &models.Crop{X: -0.1, Width: 0.5, Height: 0.5}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEdits(tt.edits)
+			var invalid *InvalidEditsError
+			if tt.valid && err != nil {
+				t.Errorf("ValidateEdits: %v", err)
+			}
+			if !tt.valid && !errors.As(err, &invalid) {
+				t.Errorf("ValidateEdits = %v, want an InvalidEditsError", err)
+			}
+		})
+	}
+}
+
+func TestApplyEditsDimensions(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	tests := []struct {
+		name          string
+		edits         models.Edits
+		width, height int
+	}{
+		{"filter only", models.Edits{Filter: FilterVintage}, 400, 200},
+		{"rotate 90", models.Edits{Rotate: 90}, 200, 400},
+		{"rotate 180", models.Edits{Rotate: 180}, 400, 200},
+		{"crop", models.Edits{Crop: &models.Crop{X: 0.5, Y: 0.25, Width: 0.5, Height: 0.5}}, 200, 100},
+		{"rotate then crop", models.Edits{Rotate: 270, Crop: &models.Crop{Width: 1, Height: 0.5}}, 200, 200},
+	}
+	for _, tt := range tests {
+		bounds := ApplyEdits(src, tt.edits).Bounds()
+		if bounds.Dx() != tt.width || bounds.Dy() != tt.height {
+			t.Errorf("%s: %dx%d, want %dx%d", tt.name, bounds.Dx(), bounds.Dy(), tt.width, tt.height)
+		}
+	}
+}
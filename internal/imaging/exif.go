@@ -0,0 +1,105 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Metadata is what the ingest pipeline keeps from a file's EXIF data.
+// Everything else, including GPS position and camera details, is dropped when
+// the image is re-encoded.
+type Metadata struct {
+	Orientation int
+	// CapturedAt is the camera's wall-clock time, which EXIF records without a
+	// time zone. It is zero if the file doesn't say.
+	CapturedAt time.Time
+}
+
+// ReadMetadata reads the EXIF data of a JPEG. Missing or broken EXIF data
+// yields zero Metadata; it never makes an otherwise valid image fail.
+func ReadMetadata(r io.Reader) (meta Metadata) {
+	// goexif parses untrusted input and has panicked on corrupt files before.
+	defer func() {
+		if recover() != nil {
+			meta = Metadata{}
+		}
+	}()
+
+	x, err := exif.Decode(r)
+	if err != nil {
+		return Metadata{}
+	}
+
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if orientation, err := tag.Int(0); err == nil {
+			meta.Orientation = orientation
+		}
+	}
+
+	for _, field := range []exif.FieldName{exif.DateTimeOriginal, exif.DateTime} {
+		tag, err := x.Get(field)
+		if err != nil {
+			continue
+		}
+		value, err := tag.StringVal()
+		if err != nil {
+			continue
+		}
+		capturedAt, err := time.ParseInLocation("2006:01:02 15:04:05", strings.TrimRight(value, "\x00 "), time.UTC)
+		if err == nil {
+			meta.CapturedAt = capturedAt
+			break
+		}
+	}
+
+	return meta
+}
+
+// Orient applies an EXIF orientation so the image displays upright without
+// the tag. Orientation 1 and unknown values leave the image as it is.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Orientations 5 to 8 turn the image on its side.
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // flip horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotate 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertically
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90° counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
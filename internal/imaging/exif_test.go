@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exifJPEG encodes img as a JPEG carrying an EXIF orientation and, unless
+// dateTime is empty, a DateTime tag.
+func exifJPEG(t *testing.T, img image.Image, orientation uint16, dateTime string) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A little-endian TIFF header followed by IFD0 at offset 8, with the
+	// DateTime string after the directory.
+	entries := 1
+	if dateTime != "" {
+		entries = 2
+	}
+	tiff := &bytes.Buffer{}
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	binary.Write(tiff, le, uint16(42))
+	binary.Write(tiff, le, uint32(8))
+	binary.Write(tiff, le, uint16(entries))
+	binary.Write(tiff, le, []uint16{0x0112, 3})
+	binary.Write(tiff, le, uint32(1))
+	binary.Write(tiff, le, []uint16{orientation, 0})
+	if dateTime != "" {
+		binary.Write(tiff, le, []uint16{0x0132, 2})
+		binary.Write(tiff, le, uint32(len(dateTime)+1))
+		binary.Write(tiff, le, uint32(8+2+12*entries+4))
+	}
+	binary.Write(tiff, le, uint32(0))
+	if dateTime != "" {
+		tiff.WriteString(dateTime + "\x00")
+	}
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2])
+	out.Write([]byte{0xff, 0xe1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(encoded.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestReadMetadata(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	tests := []struct {
+		name string
+		data []byte
+		want Metadata
+	}{
+		{"orientation and time", exifJPEG(t, img, 6, "2024:05:24 18:30:00"), Metadata{Orientation: 6, CapturedAt: time.Date(2024, 5, 24, 18, 30, 0, 0, time.UTC)}},
+		{"orientation only", exifJPEG(t, img, 3, ""), Metadata{Orientation: 3}},
+		{"unparsable time", exifJPEG(t, img, 1, "yesterday"), Metadata{Orientation: 1}},
+		{"no exif", []byte("\xff\xd8\xff\xdb not much of a jpeg"), Metadata{}},
+		{"truncated", exifJPEG(t, img, 6, "2024:05:24 18:30:00")[:40], Metadata{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReadMetadata(bytes.NewReader(tt.data)); got != tt.want {
+				t.Errorf("ReadMetadata = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOrient turns a 3x2 image whose pixels are labelled
+//
+//	A B C
+//	D E F
+//
+// upright for every EXIF orientation.
+func TestOrient(t *testing.T) {
+	labels := "ABCDEF"
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := range labels {
+		src.Set(i%3, i/3, color.RGBA{R: labels[i], A: 255})
+	}
+
+	tests := []struct {
+		orientation int
+		want        string
+	}{
+		{0, "ABC/DEF"},
+		{1, "ABC/DEF"},
+		{2, "CBA/FED"},
+		{3, "FED/CBA"},
+		{4, "DEF/ABC"},
+		{5, "AD/BE/CF"},
+		{6, "DA/EB/FC"},
+		{7, "FC/EB/DA"},
+		{8, "CF/BE/AD"},
+		{9, "ABC/DEF"},
+	}
+	for _, tt := range tests {
+		oriented := Orient(src, tt.orientation)
+		bounds := oriented.Bounds()
+		var rows []string
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row := ""
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, _, _, _ := oriented.At(x, y).RGBA()
+				row += string(rune(r >> 8))
+			}
+			rows = append(rows, row)
+		}
+		if got := strings.Join(rows, "/"); got != tt.want {
+			t.Errorf("orientation %d: got %s, want %s", tt.orientation, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+
+	"photo-booth.com/internal/models"
+)
+
+const (
+	MaxLayers        = 20
+	MaxLayerText     = 100
+	MaxTextSize      = 0.25
+	MaxStrokeWidth   = 0.2
+	defaultTextSize  = 0.08
+	defaultTextColor = "#ffffff"
+	defaultStroke    = "#000000"
+	defaultFont      = FontRegular
+	defaultScale     = 0.2
+)
+
+// StickerLoader returns the sticker image with the given name.
+type StickerLoader func(name string) (image.Image, error)
+
+// validateLayers is part of ValidateEdits. Stickers are looked up when the
+// layers are drawn.
+func validateLayers(layers []models.Layer) error {
+	if len(layers) > MaxLayers {
+		return invalidEdits("at most %d layers are allowed", MaxLayers)
+	}
+
+	for i, layer := range layers {
+		n := i + 1
+		if math.IsNaN(layer.X) || math.IsNaN(layer.Y) || layer.X < 0 || layer.X > 1 || layer.Y < 0 || layer.Y > 1 {
+			return invalidEdits("layer %d: x and y must be between 0 and 1", n)
+		}
+
+		switch layer.Type {
+		case models.LayerText:
+			if strings.TrimSpace(layer.Text) == "" {
+				return invalidEdits("layer %d: text is required", n)
+			}
+			if utf8.RuneCountInString(layer.Text) > MaxLayerText {
+				return invalidEdits("layer %d: text can be at most %d characters", n, MaxLayerText)
+			}
+			if _, ok := fontFiles[layer.Font]; layer.Font != "" && !ok {
+				return invalidEdits("layer %d: font must be one of %s", n, strings.Join(Fonts, ", "))
+			}
+			if math.IsNaN(layer.Size) || layer.Size < 0 || layer.Size > MaxTextSize {
+				return invalidEdits("layer %d: size must be between 0 and %g", n, MaxTextSize)
+			}
+			if math.IsNaN(layer.StrokeWidth) || layer.StrokeWidth < 0 || layer.StrokeWidth > MaxStrokeWidth {
+				return invalidEdits("layer %d: stroke_width must be between 0 and %g", n, MaxStrokeWidth)
+			}
+			for _, value := range []string{layer.Color, layer.StrokeColor} {
+				if _, ok := parseHex(value); value != "" && !ok {
+					return invalidEdits("layer %d: colors must be #rrggbb", n)
+				}
+			}
+		case models.LayerSticker:
+			if layer.Sticker == "" {
+				return invalidEdits("layer %d: sticker is required", n)
+			}
+			if math.IsNaN(layer.Scale) || layer.Scale < 0 || layer.Scale > 1 {
+				return invalidEdits("layer %d: scale must be between 0 and 1", n)
+			}
+			if math.IsNaN(layer.Rotation) || layer.Rotation < -360 || layer.Rotation > 360 {
+				return invalidEdits("layer %d: rotation must be between -360 and 360", n)
+			}
+		default:
+			return invalidEdits("layer %d: type must be %s or %s", n, models.LayerText, models.LayerSticker)
+		}
+	}
+	return nil
+}
+
+// ApplyLayers draws validated layers on a copy of img. Sizes left at zero
+// get defaults: text is 8% of the photo's height, white with no outline, and
+// stickers are a fifth of its width.
+func ApplyLayers(img image.Image, layers []models.Layer, stickers StickerLoader) (image.Image, error) {
+	if len(layers) == 0 {
+		return img, nil
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+
+	for _, layer := range layers {
+		if layer.Type == models.LayerSticker {
+			sticker, err := stickers(layer.Sticker)
+			if err != nil {
+				return nil, err
+			}
+			scale := layer.Scale
+			if scale == 0 {
+				scale = defaultScale
+			}
+			drawSticker(dst, sticker, layer.X*w, layer.Y*h, scale*w, layer.Rotation)
+			continue
+		}
+
+		size := layer.Size
+		if size == 0 {
+			size = defaultTextSize
+		}
+		fontName := layer.Font
+		if fontName == "" {
+			fontName = defaultFont
+		}
+		fill, _ := parseHex(defaultIfEmpty(layer.Color, defaultTextColor))
+		strokeColor, _ := parseHex(defaultIfEmpty(layer.StrokeColor, defaultStroke))
+		center := image.Pt(int(math.Round(layer.X*w)), int(math.Round(layer.Y*h)))
+		pixels := size * h
+		stroke := int(math.Round(layer.StrokeWidth * pixels))
+		if err := drawText(dst, center, layer.Text, fontName, pixels, fill, stroke, strokeColor); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// drawSticker draws sticker width pixels wide, centered on (cx, cy) and
+// turned rotation degrees clockwise.
+func drawSticker(dst draw.Image, sticker image.Image, cx, cy, width, rotation float64) {
+	sb := sticker.Bounds()
+	if sb.Empty() {
+		return
+	}
+	scale := width / float64(sb.Dx())
+	sin, cos := math.Sincos(rotation * math.Pi / 180)
+	sx, sy := float64(sb.Min.X+sb.Max.X)/2, float64(sb.Min.Y+sb.Max.Y)/2
+
+	// Scale and rotate around the sticker's center, then move it into place.
+	a, b := scale*cos, -scale*sin
+	d, e := scale*sin, scale*cos
+	transform := f64.Aff3{
+		a, b, cx - (a*sx + b*sy),
+		d, e, cy - (d*sx + e*sy),
+	}
+	xdraw.BiLinear.Transform(dst, transform, sticker, sb, draw.Over, nil)
+}
+
+func defaultIfEmpty(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
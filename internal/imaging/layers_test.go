@@ -0,0 +1,107 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"photo-booth.com/internal/models"
+)
+
+func TestValidateLayers(t *testing.T) {
+	text := func(layer models.Layer) models.Edits {
+		layer.Type = models.LayerText
+		if layer.Text == "" {
+			layer.Text = "Party"
+		}
+		return models.Edits{Layers: []models.Layer{layer}}
+	}
+	sticker := func(layer models.Layer) models.Edits {
+		layer.Type = models.LayerSticker
+		layer.Sticker = "heart"
+		return models.Edits{Layers: []models.Layer{layer}}
+	}
+
+	tests := []struct {
+		name  string
+		edits models.Edits
+		valid bool
+	}{
+		{"text", text(models.Layer{X: 0.5, Y: 0.9, Font: FontBold, Size: MaxTextSize, Color: "#ff0000", StrokeWidth: MaxStrokeWidth}), true},
+		{"blank text", text(models.Layer{Text: "   "}), false},
+		{"longest text", text(models.Layer{Text: strings.Repeat("é", MaxLayerText)}), true},
+		{"text too long", text(models.Layer{Text: strings.Repeat("a", MaxLayerText+1)}), false},
+		{"unknown font", text(models.Layer{Font: "comic"}), false},
+		{"text too big", text(models.Layer{Size: MaxTextSize + 0.01}), false},
+		{"stroke too wide", text(models.Layer{StrokeWidth: MaxStrokeWidth + 0.01}), false},
+		{"bad color", text(models.Layer{Color: "red"}), false},
+		{"position outside the photo", text(models.Layer{X: 1.5}), false},
+
+		{"sticker", sticker(models.Layer{X: 0.1, Y: 0.1, Scale: 1, Rotation: -360}), true},
+		{"sticker too big", sticker(models.Layer{Scale: 1.01}), false},
+		{"negative scale", sticker(models.Layer{Scale: -0.1}), false},
+		{"rotation too far", sticker(models.Layer{Rotation: 361}), false},
+		{"no sticker", models.Edits{Layers: []models.Layer{{Type: models.LayerSticker}}}, false},
+		{"unknown layer", models.Edits{Layers: []models.Layer{{Type: "shape"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEdits(tt.edits)
+			var invalid *InvalidEditsError
+			if tt.valid && err != nil {
+				t.Errorf("ValidateEdits: %v", err)
+			}
+			if !tt.valid && !errors.As(err, &invalid) {
+				t.Errorf("ValidateEdits = %v, want an InvalidEditsError", err)
+			}
+		})
+	}
+
+	layers := make([]models.Layer, MaxLayers+1)
+	for i := range layers {
+		layers[i] = models.Layer{Type: models.LayerText, Text: "a"}
+	}
+	if err := ValidateEdits(models.Edits{Layers: layers[:MaxLayers]}); err != nil {
+		t.Errorf("%d layers: %v", MaxLayers, err)
+	}
+	if err := ValidateEdits(models.Edits{Layers: layers}); err == nil {
+		t.Errorf("%d layers were accepted", MaxLayers+1)
+	}
+}
+
+func TestApplyLayers(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	stickers := func(name string) (image.Image, error) {
+		if name != "dot" {
+			return nil, errors.New("no such sticker")
+		}
+		dot := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		draw.Draw(dot, dot.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+		return dot, nil
+	}
+
+	layers := []models.Layer{{Type: models.LayerSticker, Sticker: "dot", X: 0.5, Y: 0.5, Scale: 0.5}}
+	out, err := ApplyLayers(src, layers, stickers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Bounds() != src.Bounds() {
+		t.Errorf("bounds = %v, want %v", out.Bounds(), src.Bounds())
+	}
+	if r, _, _, _ := out.At(100, 50).RGBA(); r>>8 != 255 {
+		t.Error("sticker not drawn at the center")
+	}
+	if r, _, _, _ := out.At(5, 5).RGBA(); r != 0 {
+		t.Error("sticker drawn outside its area")
+	}
+	if r, _, _, _ := src.At(100, 50).RGBA(); r != 0 {
+		t.Error("source image was drawn on")
+	}
+
+	if _, err := ApplyLayers(src, []models.Layer{{Type: models.LayerSticker, Sticker: "missing"}}, stickers); err == nil {
+		t.Error("missing sticker: no error")
+	}
+}
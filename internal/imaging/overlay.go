@@ -0,0 +1,75 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const MaxDimension = 4096
+
+var ErrImageTooLarge = errors.New("image dimensions exceed limit")
+
+func Decode(r io.ReadSeeker) (image.Image, error) {
+	img, _, err := decode(r)
+	return img, err
+}
+
+func decode(r io.ReadSeeker) (image.Image, string, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, "", ErrImageTooLarge
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	return image.Decode(r)
+}
+
+func ApplyOverlay(base, overlay image.Image) *image.RGBA {
+	bounds := base.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), base, bounds.Min, draw.Src)
+
+	xdraw.ApproxBiLinear.Scale(dst, dst.Bounds(), overlay, overlay.Bounds(), draw.Over, nil)
+
+	return dst
+}
+
+var (
+	ErrOverlayNotPNG = errors.New("overlays must be PNG images")
+	ErrOverlayOpaque = errors.New("overlay has no transparent pixels")
+)
+
+// ValidateOverlay checks that an uploaded overlay is a PNG the photo can show
+// through. A fully opaque overlay would cover the whole capture.
+func ValidateOverlay(upload *Upload) error {
+	if upload.Format != FormatPNG {
+		return ErrOverlayNotPNG
+	}
+
+	img := upload.Image
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return ErrOverlayOpaque
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0xffff {
+				return nil
+			}
+		}
+	}
+	return ErrOverlayOpaque
+}
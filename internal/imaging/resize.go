@@ -0,0 +1,38 @@
+package imaging
+
+import (
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+func Resize(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	if width <= 0 || width >= bounds.Dx() {
+		return src
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, xdraw.Src, nil)
+	return dst
+}
+
+// Fit scales src down so that neither side is larger than size.
+func Fit(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width := bounds.Dx()
+	if bounds.Dy() > bounds.Dx() {
+		width = bounds.Dx() * size / bounds.Dy()
+	} else if width > size {
+		width = size
+	}
+	if width < 1 {
+		width = 1
+	}
+	return Resize(src, width)
+}
@@ -0,0 +1,26 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		width, height int
+		size          int
+		wantW, wantH  int
+	}{
+		{1920, 1080, BurstFrameSize, 960, 540},
+		{1080, 1920, BurstFrameSize, 540, 960},
+		{640, 480, BurstFrameSize, 640, 480},
+		{960, 960, BurstFrameSize, 960, 960},
+		{4000, 1, 100, 100, 1},
+	}
+	for _, tt := range tests {
+		bounds := Fit(image.NewRGBA(image.Rect(0, 0, tt.width, tt.height)), tt.size).Bounds()
+		if bounds.Dx() != tt.wantW || bounds.Dy() != tt.wantH {
+			t.Errorf("Fit(%dx%d, %d) = %dx%d, want %dx%d", tt.width, tt.height, tt.size, bounds.Dx(), bounds.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
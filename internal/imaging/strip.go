@@ -0,0 +1,191 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	LayoutStrip = "strip"
+	LayoutGrid  = "grid"
+)
+
+var Layouts = []string{LayoutStrip, LayoutGrid}
+
+const (
+	MinStripFrames = 2
+	MaxStripFrames = 4
+	// MaxStripBorder caps both the border and the spacing, in pixels.
+	MaxStripBorder  = 200
+	MaxFooterLength = 60
+	// stripFrameWidth is the widest a frame is drawn in a strip. Frames are
+	// also kept short enough for the whole strip to fit MaxDimension.
+	stripFrameWidth = 800
+)
+
+// StripOptions describe how the frames of a photo strip are laid out.
+type StripOptions struct {
+	Layout     string
+	Border     int
+	Spacing    int
+	Background color.NRGBA
+	Footer     string
+}
+
+// InvalidStripError reports why a photo strip was rejected.
+type InvalidStripError struct {
+	Reason string
+}
+
+func (e *InvalidStripError) Error() string {
+	return "invalid strip: " + e.Reason
+}
+
+func invalidStrip(format string, args ...any) error {
+	return &InvalidStripError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// ValidateStrip checks the options and number of frames before anything is
+// decoded or rendered. A strip takes 2 to 4 frames; a grid exactly 4.
+func ValidateStrip(frames int, options StripOptions) error {
+	switch options.Layout {
+	case LayoutStrip:
+		if frames < MinStripFrames || frames > MaxStripFrames {
+			return invalidStrip("a strip needs %d to %d frames", MinStripFrames, MaxStripFrames)
+		}
+	case LayoutGrid:
+		if frames != 4 {
+			return invalidStrip("a grid needs 4 frames")
+		}
+	default:
+		return invalidStrip("layout must be %s", strings.Join(Layouts, " or "))
+	}
+
+	if options.Border < 0 || options.Border > MaxStripBorder {
+		return invalidStrip("border must be between 0 and %d", MaxStripBorder)
+	}
+	if options.Spacing < 0 || options.Spacing > MaxStripBorder {
+		return invalidStrip("spacing must be between 0 and %d", MaxStripBorder)
+	}
+	if utf8.RuneCountInString(options.Footer) > MaxFooterLength {
+		return invalidStrip("footer can be at most %d characters", MaxFooterLength)
+	}
+	return nil
+}
+
+// ParseColor parses a strip's "#rrggbb" background color.
+func ParseColor(value string) (color.NRGBA, error) {
+	c, ok := parseHex(value)
+	if !ok {
+		return color.NRGBA{}, invalidStrip("background must be a #rrggbb color")
+	}
+	return c, nil
+}
+
+func parseHex(value string) (color.NRGBA, bool) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 {
+		return color.NRGBA{}, false
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, true
+}
+
+// ComposeStrip lays validated frames out top to bottom, or in a 2x2 grid,
+// on the background color with the footer text below them. Every frame is
+// drawn at the size of the first one, cropped to fit if its shape differs,
+// and frames are scaled down until the strip fits MaxDimension.
+func ComposeStrip(frames []image.Image, options StripOptions) (*image.NRGBA, error) {
+	columns, rows := 1, len(frames)
+	if options.Layout == LayoutGrid {
+		columns, rows = 2, 2
+	}
+
+	first := frames[0].Bounds()
+	frameWidth, frameHeight := first.Dx(), first.Dy()
+	if frameWidth > stripFrameWidth {
+		frameHeight = frameHeight * stripFrameWidth / frameWidth
+		frameWidth = stripFrameWidth
+	}
+	// Shrinking tall frames only makes the footer smaller, so the strip fits.
+	_, footerHeight := stripFooter(columns*frameWidth+(columns-1)*options.Spacing, options.Footer)
+	available := (MaxDimension - 2*options.Border - (rows-1)*options.Spacing - footerHeight) / rows
+	if frameHeight > available {
+		frameWidth = frameWidth * available / frameHeight
+		frameHeight = available
+		if frameWidth < 1 {
+			frameWidth = 1
+		}
+	}
+
+	contentWidth := columns*frameWidth + (columns-1)*options.Spacing
+	contentHeight := rows*frameHeight + (rows-1)*options.Spacing
+	footerSize, footerHeight := stripFooter(contentWidth, options.Footer)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, contentWidth+2*options.Border, contentHeight+footerHeight+2*options.Border))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(options.Background), image.Point{}, draw.Src)
+
+	for i, frame := range frames {
+		x := options.Border + (i%columns)*(frameWidth+options.Spacing)
+		y := options.Border + (i/columns)*(frameHeight+options.Spacing)
+		cell := image.Rect(x, y, x+frameWidth, y+frameHeight)
+		xdraw.CatmullRom.Scale(dst, cell, frame, coverRect(frame.Bounds(), frameWidth, frameHeight), draw.Src, nil)
+	}
+
+	if options.Footer != "" {
+		top := options.Border + contentHeight
+		footer := image.Rect(options.Border, top, options.Border+contentWidth, top+footerHeight)
+		text := color.Color(color.Black)
+		if luma(float64(options.Background.R), float64(options.Background.G), float64(options.Background.B)) < 128 {
+			text = color.White
+		}
+		if err := drawCentered(dst, footer, options.Footer, footerSize, text); err != nil {
+			return nil, err
+		}
+	}
+
+	return dst, nil
+}
+
+// stripFooter returns the font size and height of the footer below content
+// of the given width. Without footer text there is no footer.
+func stripFooter(contentWidth int, text string) (float64, int) {
+	size := float64(contentWidth) / 16
+	if size < 12 {
+		size = 12
+	}
+	if text == "" {
+		return size, 0
+	}
+	return size, int(size * 2)
+}
+
+// coverRect returns the largest centered part of bounds with the shape of a
+// width x height frame.
+func coverRect(bounds image.Rectangle, width, height int) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	if w*height > h*width {
+		cropped := h * width / height
+		if cropped < 1 {
+			cropped = 1
+		}
+		x := bounds.Min.X + (w-cropped)/2
+		return image.Rect(x, bounds.Min.Y, x+cropped, bounds.Max.Y)
+	}
+	cropped := w * height / width
+	if cropped < 1 {
+		cropped = 1
+	}
+	y := bounds.Min.Y + (h-cropped)/2
+	return image.Rect(bounds.Min.X, y, bounds.Max.X, y+cropped)
+}
@@ -0,0 +1,117 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestValidateStrip(t *testing.T) {
+	strip := StripOptions{Layout: LayoutStrip, Border: 40, Spacing: 20}
+	with := func(change func(*StripOptions)) StripOptions {
+		options := strip
+		change(&options)
+		return options
+	}
+
+	tests := []struct {
+		name    string
+		frames  int
+		options StripOptions
+		valid   bool
+	}{
+		{"fewest frames", MinStripFrames, strip, true},
+		{"most frames", MaxStripFrames, strip, true},
+		{"too few frames", MinStripFrames - 1, strip, false},
+		{"too many frames", MaxStripFrames + 1, strip, false},
+		{"grid", 4, with(func(o *StripOptions) { o.Layout = LayoutGrid }), true},
+		{"grid of three", 3, with(func(o *StripOptions) { o.Layout = LayoutGrid }), false},
+		{"unknown layout", 3, with(func(o *StripOptions) { o.Layout = "circle" }), false},
+		{"no border", 3, with(func(o *StripOptions) { o.Border, o.Spacing = 0, 0 }), true},
+		{"widest border", 3, with(func(o *StripOptions) { o.Border, o.Spacing = MaxStripBorder, MaxStripBorder }), true},
+		{"border too wide", 3, with(func(o *StripOptions) { o.Border = MaxStripBorder + 1 }), false},
+		{"negative spacing", 3, with(func(o *StripOptions) { o.Spacing = -1 }), false},
+		{"longest footer", 3, with(func(o *StripOptions) { o.Footer = strings.Repeat("ü", MaxFooterLength) }), true},
+		{"footer too long", 3, with(func(o *StripOptions) { o.Footer = strings.Repeat("a", MaxFooterLength+1) }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStrip(tt.frames, tt.options)
+			var invalid *InvalidStripError
+			if tt.valid && err != nil {
+				t.Errorf("ValidateStrip: %v", err)
+			}
+			if !tt.valid && !errors.As(err, &invalid) {
+				t.Errorf("ValidateStrip = %v, want an InvalidStripError", err)
+			}
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	if got, err := ParseColor("#1e90ff"); err != nil || got != (color.NRGBA{R: 0x1e, G: 0x90, B: 0xff, A: 255}) {
+		t.Errorf("ParseColor(#1e90ff) = %v, %v", got, err)
+	}
+	for _, value := range []string{"", "white", "#fff", "#12345g", "#1234567"} {
+		if _, err := ParseColor(value); err == nil {
+			t.Errorf("ParseColor(%q) accepted", value)
+		}
+	}
+}
+
+func TestComposeStripDimensions(t *testing.T) {
+	frames := func(n, width, height int) []image.Image {
+		images := make([]image.Image, n)
+		for i := range images {
+			images[i] = image.NewRGBA(image.Rect(0, 0, width, height))
+		}
+		return images
+	}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	tests := []struct {
+		name          string
+		frames        []image.Image
+		options       StripOptions
+		width, height int
+	}{
+		{"strip", frames(3, 400, 300), StripOptions{Layout: LayoutStrip, Border: 40, Spacing: 20}, 480, 1020},
+		{"grid", frames(4, 400, 300), StripOptions{Layout: LayoutGrid, Border: 40, Spacing: 20}, 900, 700},
+		{"no border", frames(2, 400, 300), StripOptions{Layout: LayoutStrip}, 400, 600},
+		// The footer is twice a sixteenth of the content width high.
+		{"footer", frames(3, 400, 300), StripOptions{Layout: LayoutStrip, Border: 40, Spacing: 20, Footer: "Anna & Ben"}, 480, 1070},
+		{"wide frames", frames(2, 1600, 1200), StripOptions{Layout: LayoutStrip}, 800, 1200},
+		{"mixed shapes", []image.Image{image.NewRGBA(image.Rect(0, 0, 400, 300)), image.NewRGBA(image.Rect(0, 0, 300, 400))}, StripOptions{Layout: LayoutStrip}, 400, 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.Background = white
+			strip, err := ComposeStrip(tt.frames, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bounds := strip.Bounds(); bounds.Dx() != tt.width || bounds.Dy() != tt.height {
+				t.Errorf("%dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), tt.width, tt.height)
+			}
+		})
+	}
+}
+
+// TestComposeStripSizeLimit checks that strips of tall frames are scaled down
+// to fit MaxDimension, whatever the border, spacing and footer.
+func TestComposeStripSizeLimit(t *testing.T) {
+	frames := make([]image.Image, MaxStripFrames)
+	for i := range frames {
+		frames[i] = image.NewRGBA(image.Rect(0, 0, 800, MaxDimension))
+	}
+	options := StripOptions{Layout: LayoutStrip, Border: MaxStripBorder, Spacing: MaxStripBorder, Footer: "Footer", Background: color.NRGBA{A: 255}}
+	strip, err := ComposeStrip(frames, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := strip.Bounds(); bounds.Dx() > MaxDimension || bounds.Dy() > MaxDimension {
+		t.Errorf("strip is %dx%d, over the %d pixel limit", bounds.Dx(), bounds.Dy(), MaxDimension)
+	}
+}
@@ -0,0 +1,208 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	FontRegular = "regular"
+	FontBold    = "bold"
+	FontItalic  = "italic"
+	FontMono    = "mono"
+)
+
+// Fonts are the bundled Go fonts text can be drawn in.
+var Fonts = []string{FontRegular, FontBold, FontItalic, FontMono}
+
+var fontFiles = map[string][]byte{
+	FontRegular: goregular.TTF,
+	FontBold:    gobold.TTF,
+	FontItalic:  goitalic.TTF,
+	FontMono:    gomono.TTF,
+}
+
+var (
+	fontsMu sync.Mutex
+	fonts   = map[string]*opentype.Font{}
+)
+
+// fontFace returns one of the bundled fonts at size pixels.
+func fontFace(name string, size float64) (font.Face, error) {
+	fontsMu.Lock()
+	parsed, ok := fonts[name]
+	if !ok {
+		var err error
+		parsed, err = opentype.Parse(fontFiles[name])
+		if err != nil {
+			fontsMu.Unlock()
+			return nil, err
+		}
+		fonts[name] = parsed
+	}
+	fontsMu.Unlock()
+
+	return opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// drawCentered draws a line of text centered in rect, shrinking the font
+// until the text fits the width.
+func drawCentered(dst *image.NRGBA, rect image.Rectangle, text string, size float64, c color.Color) error {
+	for {
+		face, err := fontFace(FontRegular, size)
+		if err != nil {
+			return err
+		}
+		width := font.MeasureString(face, text).Ceil()
+		if width > rect.Dx() && size > 8 {
+			face.Close()
+			size *= 0.9
+			continue
+		}
+		defer face.Close()
+
+		metrics := face.Metrics()
+		height := (metrics.Ascent + metrics.Descent).Ceil()
+		drawer := &font.Drawer{
+			Dst:  dst,
+			Src:  image.NewUniform(c),
+			Face: face,
+			Dot: fixed.P(
+				rect.Min.X+(rect.Dx()-width)/2,
+				rect.Min.Y+(rect.Dy()-height)/2+metrics.Ascent.Ceil(),
+			),
+		}
+		drawer.DrawString(text)
+		return nil
+	}
+}
+
+// drawText draws a line of text centered on center, outlined stroke pixels
+// wide if stroke is positive.
+func drawText(dst draw.Image, center image.Point, text, fontName string, size float64, fill color.Color, stroke int, strokeColor color.Color) error {
+	face, err := fontFace(fontName, size)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	width := font.MeasureString(face, text).Ceil()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+	origin := image.Pt(center.X-width/2, center.Y-height/2)
+
+	// Only the part of the text that can reach the photo is rasterized.
+	rect := image.Rect(origin.X, origin.Y, origin.X+width, origin.Y+height).Inset(-stroke)
+	rect = rect.Intersect(dst.Bounds().Inset(-stroke))
+	if rect.Empty() {
+		return nil
+	}
+
+	mask := image.NewAlpha(rect)
+	drawer := &font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(origin.X, origin.Y+metrics.Ascent.Ceil()),
+	}
+	drawer.DrawString(text)
+
+	if stroke > 0 {
+		draw.DrawMask(dst, rect, image.NewUniform(strokeColor), image.Point{}, outline(mask, stroke), rect.Min, draw.Over)
+	}
+	draw.DrawMask(dst, rect, image.NewUniform(fill), image.Point{}, mask, rect.Min, draw.Over)
+	return nil
+}
+
+// outline grows the glyphs in mask by radius pixels. It uses a Euclidean
+// distance transform, so the cost doesn't depend on the radius.
+func outline(mask *image.Alpha, radius int) *image.Alpha {
+	bounds := mask.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dist := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if mask.Pix[y*mask.Stride+x] < 128 {
+				dist[y*w+x] = math.Inf(1)
+			}
+		}
+	}
+	column := make([]float64, h)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			column[y] = dist[y*w+x]
+		}
+		distance1D(column)
+		for y := 0; y < h; y++ {
+			dist[y*w+x] = column[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		distance1D(dist[y*w : (y+1)*w])
+	}
+
+	grown := image.NewAlpha(bounds)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			coverage := float64(radius) + 0.5 - math.Sqrt(dist[y*w+x])
+			i := y*grown.Stride + x
+			grown.Pix[i] = clamp(coverage * 255)
+			if own := mask.Pix[y*mask.Stride+x]; own > grown.Pix[i] {
+				grown.Pix[i] = own
+			}
+		}
+	}
+	return grown
+}
+
+// distance1D replaces f with its squared distance transform, in place, using
+// the lower envelope of parabolas from Felzenszwalb and Huttenlocher.
+// Infinite samples are the background and add no parabola.
+func distance1D(f []float64) {
+	// v holds the samples whose parabolas form the envelope, z where each of
+	// them starts to be the lowest.
+	v := make([]int, 0, len(f))
+	z := make([]float64, 0, len(f))
+	for q := range f {
+		if math.IsInf(f[q], 1) {
+			continue
+		}
+		s := math.Inf(-1)
+		for len(v) > 0 {
+			p := v[len(v)-1]
+			s = ((f[q] + float64(q*q)) - (f[p] + float64(p*p))) / float64(2*(q-p))
+			if s > z[len(z)-1] {
+				break
+			}
+			v, z = v[:len(v)-1], z[:len(z)-1]
+			s = math.Inf(-1)
+		}
+		v = append(v, q)
+		z = append(z, s)
+	}
+	if len(v) == 0 {
+		return
+	}
+
+	d := make([]float64, len(f))
+	k := 0
+	for q := range f {
+		for k+1 < len(v) && z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+	}
+	copy(f, d)
+}
@@ -0,0 +1,90 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"io"
+	"time"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+// Upload is a validated image, turned upright.
+type Upload struct {
+	Image  image.Image
+	Format string
+	// CapturedAt is the EXIF capture time, or zero if the file has none.
+	CapturedAt time.Time
+}
+
+var (
+	ErrInvalidImage      = errors.New("invalid image data")
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+)
+
+// Sniff identifies the format from the magic bytes at the start of a file.
+func Sniff(header []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte("\xff\xd8\xff")):
+		return FormatJPEG, nil
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG, nil
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return FormatGIF, nil
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return FormatWebP, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// DecodeUpload decodes an untrusted image. The magic bytes must name a
+// supported format and the whole file must decode as that format, so files
+// that only pretend to be images are rejected. JPEGs are rotated according to
+// their EXIF orientation. Animated GIFs decode to their first frame.
+func DecodeUpload(r io.ReadSeeker) (*Upload, error) {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrInvalidImage
+	}
+	format, err := Sniff(header[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	img, decoded, err := decode(r)
+	if errors.Is(err, ErrImageTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if decoded != format {
+		return nil, ErrInvalidImage
+	}
+
+	upload := &Upload{Image: img, Format: format}
+	if format == FormatJPEG {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		meta := ReadMetadata(r)
+		upload.Image = Orient(img, meta.Orientation)
+		upload.CapturedAt = meta.CapturedAt
+	}
+	return upload, nil
+}
@@ -0,0 +1,99 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"\xff\xd8\xff\xe0", FormatJPEG},
+		{"\x89PNG\r\n\x1a\n", FormatPNG},
+		{"GIF87a", FormatGIF},
+		{"GIF89a", FormatGIF},
+		{"RIFF\x00\x00\x00\x00WEBP", FormatWebP},
+		{"RIFF\x00\x00\x00\x00WAVE", ""},
+		{"<svg xmlns", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := Sniff([]byte(tt.header))
+		if got != tt.want || (tt.want == "") != errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("Sniff(%q) = %q, %v, want %q", tt.header, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeUpload(t *testing.T) {
+	var animated bytes.Buffer
+	frame := image.NewPaletted(image.Rect(0, 0, 6, 4), []color.Color{color.Black, color.White})
+	if err := gif.EncodeAll(&animated, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	pngData := encodePNG(t, 6, 4)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		err        error
+		format     string
+		width      int
+		height     int
+		capturedAt time.Time
+	}{
+		{name: "png", data: pngData, format: FormatPNG, width: 6, height: 4},
+		{name: "animated gif", data: animated.Bytes(), format: FormatGIF, width: 6, height: 4},
+		{name: "widest allowed", data: encodePNG(t, MaxDimension, 1), format: FormatPNG, width: MaxDimension, height: 1},
+		{name: "too wide", data: encodePNG(t, MaxDimension+1, 1), err: ErrImageTooLarge},
+		{name: "too tall", data: encodePNG(t, 1, MaxDimension+1), err: ErrImageTooLarge},
+		{name: "truncated", data: pngData[:len(pngData)/2], err: ErrInvalidImage},
+		{name: "jpeg header on a png", data: append([]byte("\xff\xd8\xff"), pngData...), err: ErrInvalidImage},
+		{name: "gif header on a png", data: append([]byte("GIF89a"), pngData[6:]...), err: ErrInvalidImage},
+		{name: "script", data: []byte("<script>alert(1)</script>"), err: ErrUnsupportedFormat},
+		{name: "empty", data: nil, err: ErrInvalidImage},
+		{
+			name:       "rotated jpeg",
+			data:       exifJPEG(t, image.NewRGBA(image.Rect(0, 0, 6, 4)), 6, "2024:05:24 18:30:00"),
+			format:     FormatJPEG,
+			width:      4,
+			height:     6,
+			capturedAt: time.Date(2024, 5, 24, 18, 30, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload, err := DecodeUpload(bytes.NewReader(tt.data))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			bounds := upload.Image.Bounds()
+			if upload.Format != tt.format || bounds.Dx() != tt.width || bounds.Dy() != tt.height || !upload.CapturedAt.Equal(tt.capturedAt) {
+				t.Errorf("got %s %dx%d taken %v, want %s %dx%d taken %v", upload.Format, bounds.Dx(), bounds.Dy(), upload.CapturedAt, tt.format, tt.width, tt.height, tt.capturedAt)
+			}
+		})
+	}
+}
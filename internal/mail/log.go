@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"log"
+	"sync"
+)
+
+// LogMailer prints messages instead of delivering them. It is the default
+// during development.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	log.Printf("[MAIL] To: %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// CaptureMailer keeps sent messages in memory so they can be inspected.
+type CaptureMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (c *CaptureMailer) Send(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+func (c *CaptureMailer) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Message(nil), c.messages...)
+}
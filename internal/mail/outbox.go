@@ -0,0 +1,124 @@
+package mail
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
+)
+
+const (
+	outboxBatchSize    = 10
+	outboxPollInterval = 5 * time.Second
+	outboxLease        = 5 * time.Minute
+	outboxBaseBackoff  = 30 * time.Second
+	outboxMaxBackoff   = time.Hour
+	outboxMaxAttempts  = 8
+	outboxDrainTimeout = 15 * time.Second
+)
+
+// Outbox is a Mailer that stores messages in the database. Run delivers them
+// through the wrapped Mailer, retrying failures with exponential backoff until
+// they are sent or marked dead after outboxMaxAttempts.
+type Outbox struct {
+	store  store.OutboxStore
+	mailer Mailer
+	wake   chan struct{}
+}
+
+func NewOutbox(outboxStore store.OutboxStore, mailer Mailer) *Outbox {
+	return &Outbox{
+		store:  outboxStore,
+		mailer: mailer,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+func (o *Outbox) Send(msg Message) error {
+	email := models.OutboxEmail{To: msg.To, Subject: msg.Subject, Text: msg.Text, HTML: msg.HTML}
+	if err := o.store.Enqueue(&email); err != nil {
+		return err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers queued messages until ctx is cancelled, then keeps draining
+// messages that are already due for up to outboxDrainTimeout.
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		o.deliverAll(time.Time{})
+
+		select {
+		case <-ctx.Done():
+			o.deliverAll(time.Now().Add(outboxDrainTimeout))
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+func (o *Outbox) deliverAll(deadline time.Time) {
+	for deadline.IsZero() || time.Now().Before(deadline) {
+		delivered, err := o.deliverDue()
+		if err != nil {
+			log.Printf("Error processing email outbox: %v", err)
+			return
+		}
+		if delivered < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (o *Outbox) deliverDue() (int, error) {
+	now := time.Now()
+	emails, err := o.store.Claim(now, now.Add(outboxLease), outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, email := range emails {
+		err := o.mailer.Send(Message{To: email.To, Subject: email.Subject, Text: email.Text, HTML: email.HTML})
+		if err == nil {
+			if err := o.store.MarkSent(email.ID, time.Now()); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		dead := email.Attempts >= outboxMaxAttempts
+		if dead {
+			log.Printf("Giving up on email %d to %s after %d attempts: %v", email.ID, email.To, email.Attempts, err)
+		} else {
+			log.Printf("Error sending email %d to %s (attempt %d): %v", email.ID, email.To, email.Attempts, err)
+		}
+
+		if err := o.store.MarkFailed(email.ID, err.Error(), time.Now().Add(backoff(email.Attempts)), dead); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(emails), nil
+}
+
+func backoff(attempts int) time.Duration {
+	delay := outboxBaseBackoff
+	for i := 1; i < attempts && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxBackoff {
+		delay = outboxMaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,90 @@
+package mail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"net/url"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+const TemplateDir = "templates/email"
+
+// Service renders the application's emails from templates/email and hands
+// them to a Mailer. Every message has a <name>.txt template, which also
+// defines the "subject" block, and a <name>.html template.
+type Service struct {
+	mailer  Mailer
+	baseURL string
+}
+
+func NewService(mailer Mailer, baseURL string) *Service {
+	return &Service{mailer: mailer, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *Service) SendConfirmation(email, token string) error {
+	return s.send(email, "confirmation", struct {
+		Link string
+	}{
+		Link: s.baseURL + "/confirm?token=" + url.QueryEscape(token),
+	})
+}
+
+func (s *Service) SendPasswordReset(email, token string) error {
+	return s.send(email, "password_reset", struct {
+		Link string
+	}{
+		Link: s.baseURL + "/password/change?token=" + url.QueryEscape(token),
+	})
+}
+
+func (s *Service) SendCommentNotification(email, username, comment string) error {
+	return s.send(email, "comment_notification", struct {
+		Username string
+		Comment  string
+		Link     string
+	}{
+		Username: username,
+		Comment:  comment,
+		Link:     s.baseURL + "/gallery",
+	})
+}
+
+func (s *Service) send(to, name string, data any) error {
+	msg, err := Render(name, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	return s.mailer.Send(msg)
+}
+
+func Render(name string, data any) (Message, error) {
+	textTmpl, err := texttemplate.ParseFiles(filepath.Join(TemplateDir, name+".txt"))
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+
+	htmlTmpl, err := htmltemplate.ParseFiles(filepath.Join(TemplateDir, name+".html"))
+	if err != nil {
+		return Message{}, err
+	}
+	if err := htmlTmpl.Execute(&html, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
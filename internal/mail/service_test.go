@@ -0,0 +1,93 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The email templates are found relative to the repository root.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+func TestService(t *testing.T) {
+	mailer := &CaptureMailer{}
+	service := NewService(mailer, "https://booth.example.com/")
+
+	tests := []struct {
+		name    string
+		send    func() error
+		subject string
+		text    []string
+		html    []string
+	}{
+		{
+			name:    "confirmation",
+			send:    func() error { return service.SendConfirmation("alice@example.com", "tok&en") },
+			subject: "Confirm your Photo Booth account",
+			text:    []string{"https://booth.example.com/confirm?token=tok%26en"},
+			html:    []string{`href="https://booth.example.com/confirm?token=tok%26en"`},
+		},
+		{
+			name:    "password reset",
+			send:    func() error { return service.SendPasswordReset("alice@example.com", "reset") },
+			subject: "Reset your Photo Booth password",
+			text:    []string{"https://booth.example.com/password/change?token=reset"},
+			html:    []string{`href="https://booth.example.com/password/change?token=reset"`},
+		},
+		{
+			name: "comment notification",
+			send: func() error {
+				return service.SendCommentNotification("alice@example.com", "alice", "<b>Great</b> shot!")
+			},
+			subject: "New comment on your photo",
+			text:    []string{"Hi alice,", `"<b>Great</b> shot!"`, "https://booth.example.com/gallery"},
+			html:    []string{"Hi alice,", "&lt;b&gt;Great&lt;/b&gt; shot!", `href="https://booth.example.com/gallery"`},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.send(); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			messages := mailer.Messages()
+			if len(messages) != i+1 {
+				t.Fatalf("captured %d messages, want %d", len(messages), i+1)
+			}
+			msg := messages[i]
+
+			if msg.To != "alice@example.com" {
+				t.Errorf("To = %q", msg.To)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if strings.Contains(msg.Text, "subject") || !strings.HasSuffix(msg.Text, "\n") {
+				t.Errorf("Text = %q", msg.Text)
+			}
+			for _, want := range tt.text {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("Text is missing %q:\n%s", want, msg.Text)
+				}
+			}
+			for _, want := range tt.html {
+				if !strings.Contains(msg.HTML, want) {
+					t.Errorf("HTML is missing %q:\n%s", want, msg.HTML)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	if _, err := Render("missing", nil); err == nil {
+		t.Error("Render of a missing template succeeded")
+	}
+}
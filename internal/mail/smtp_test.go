@@ -0,0 +1,309 @@
+package mail
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sinkMessage is one message accepted by smtpSink.
+type sinkMessage struct {
+	from, to string
+	auth     string
+	tls      bool
+	data     []byte
+}
+
+// smtpSink is a local SMTP server that accepts every message and hands it to
+// the test. It offers STARTTLS when it has a certificate.
+type smtpSink struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	messages  chan sinkMessage
+	errors    chan error
+}
+
+func newSMTPSink(t *testing.T, tlsConfig *tls.Config) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{
+		listener:  listener,
+		tlsConfig: tlsConfig,
+		messages:  make(chan sinkMessage, 1),
+		errors:    make(chan error, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if err := sink.serve(conn); err != nil {
+				sink.errors <- err
+			}
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpSink) serve(conn net.Conn) error {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 sink ESMTP")
+
+	var msg sinkMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return err
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-sink")
+			if s.tlsConfig != nil && !msg.tls {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			text.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return err
+			}
+			text = textproto.NewConn(tlsConn)
+			msg.tls = true
+		case "AUTH":
+			credentials, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			if err != nil {
+				text.PrintfLine("501 malformed credentials")
+				continue
+			}
+			msg.auth = string(credentials)
+			text.PrintfLine("235 accepted")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			msg.to = strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			msg.data, err = text.ReadDotBytes()
+			if err != nil {
+				return err
+			}
+			text.PrintfLine("250 queued")
+			s.messages <- msg
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return nil
+		default:
+			text.PrintfLine("502 %s not implemented", verb)
+		}
+	}
+}
+
+func (s *smtpSink) receive(t *testing.T) sinkMessage {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case err := <-s.errors:
+		t.Fatalf("sink: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("sink received no message")
+	}
+	return sinkMessage{}
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1 and a pool
+// that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sink"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+var testMessage = Message{
+	To:      "Alice <alice@example.com>",
+	Subject: "Your photo — ready",
+	Text:    "Hi Alice,\n\nYour photo is ready: https://booth.example.com/gallery?page=1&sort=new\n",
+	HTML:    `<p>Hi Alice,</p><p><a href="https://booth.example.com/gallery?page=1&amp;sort=new">Your photo is ready</a></p>`,
+}
+
+func TestSMTPMailer(t *testing.T) {
+	cert, pool := testCertificate(t)
+
+	tests := []struct {
+		mode     string
+		username string
+		wantTLS  bool
+	}{
+		{mode: "starttls", username: "booth", wantTLS: true},
+		{mode: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var sinkTLS *tls.Config
+			if tt.mode == "starttls" {
+				sinkTLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+			}
+			sink := newSMTPSink(t, sinkTLS)
+
+			mailer, err := NewSMTP(SMTPConfig{
+				Host:     "127.0.0.1",
+				Port:     sink.port(),
+				Username: tt.username,
+				Password: "secret",
+				TLS:      tt.mode,
+				From:     "Photo Booth <booth@example.com>",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mailer.rootCAs = pool
+
+			if err := mailer.Send(testMessage); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			got := sink.receive(t)
+
+			if got.tls != tt.wantTLS {
+				t.Errorf("delivered over TLS = %v, want %v", got.tls, tt.wantTLS)
+			}
+			wantAuth := ""
+			if tt.username != "" {
+				wantAuth = "\x00booth\x00secret"
+			}
+			if got.auth != wantAuth {
+				t.Errorf("AUTH PLAIN = %q, want %q", got.auth, wantAuth)
+			}
+			if got.from != "booth@example.com" || got.to != "alice@example.com" {
+				t.Errorf("envelope = %q -> %q", got.from, got.to)
+			}
+			checkMessage(t, got.data)
+		})
+	}
+}
+
+func TestSMTPMailerUntrustedCertificate(t *testing.T) {
+	cert, _ := testCertificate(t)
+	sink := newSMTPSink(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	mailer, err := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: sink.port(), From: "booth@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(testMessage); err == nil {
+		t.Error("Send succeeded with a certificate the client does not trust")
+	}
+}
+
+// checkMessage parses data as the sink received it and compares it with
+// testMessage.
+func checkMessage(t *testing.T, data []byte) {
+	t.Helper()
+	msg, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+
+	if got := msg.Header.Get("From"); got != `"Photo Booth" <booth@example.com>` {
+		t.Errorf("From = %q", got)
+	}
+	if got := msg.Header.Get("To"); got != `"Alice" <alice@example.com>` {
+		t.Errorf("To = %q", got)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != testMessage.Subject {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(bufio.NewReader(msg.Body), params["boundary"])
+	for _, want := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", testMessage.Text},
+		{"text/html; charset=utf-8", testMessage.HTML},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("reading %s part: %v", want.contentType, err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, want.contentType)
+		}
+		// The reader removes the quoted-printable encoding.
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want.content {
+			t.Errorf("%s part = %q, want %q", want.contentType, content, want.content)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("extra parts: %v", err)
+	}
+}
@@ -2,35 +2,30 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
-	"os"
-
-	"github.com/gorilla/sessions"
-	"github.com/joho/godotenv"
+	"photo-booth.com/internal/store"
 )
 
-var Store *sessions.CookieStore
+// Store holds the sessions of all requests. It is set up in main once the
+// database is open.
+var Store *SessionStore
 
 type contextKey string
 
-func init() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
-	}
-	if os.Getenv("JWT_SECRET") == "" {
-		log.Fatal("JWT_SECRET is not set in .env file")
-	}
-
-	Store = sessions.NewCookieStore([]byte(os.Getenv("JWT_SECRET")))
-}
-
 const UserIDKey contextKey = "user_id"
 const AuthenticatedKey contextKey = "authenticated"
 
 func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if APIToken(r) != nil {
+			http.Error(w, "This endpoint cannot be used with an API token", http.StatusForbidden)
+			return
+		}
+
 		session, _ := Store.Get(r, "session")
 		if session.Values["authenticated"] != true {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
@@ -48,8 +43,43 @@ func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
+// LimitBody caps request bodies before anything parses them.
+func LimitBody(limit int64, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthMiddleware identifies the user from an "Authorization: Bearer" API
+// token if one is sent, and from the session cookie otherwise.
+func AuthMiddleware(tokens store.APITokenStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if raw, ok := bearerToken(r); ok {
+			token, err := tokens.GetByHash(HashAPIToken(raw))
+			if errors.Is(err, store.ErrNotFound) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				Error(w, r, "Invalid API token", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				Error(w, r, "Unable to verify API token", http.StatusInternalServerError)
+				return
+			}
+
+			if time.Since(token.LastUsedAt) > time.Minute {
+				if err := tokens.Touch(token.ID, time.Now()); err != nil {
+					log.Printf("Error updating API token %d: %v", token.ID, err)
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), APITokenKey, token)
+			ctx = context.WithValue(ctx, AuthenticatedKey, true)
+			ctx = context.WithValue(ctx, UserIDKey, token.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		session, _ := Store.Get(r, "session")
 		authenticated := session.Values["authenticated"] == true
 		userID, _ := session.Values["user_id"].(int)
@@ -0,0 +1,151 @@
+package migrations_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"photo-booth.com/internal/dialect"
+	"photo-booth.com/internal/migrations"
+	"photo-booth.com/internal/store"
+)
+
+// legacySchema is the schema createTables set up before migrations existed.
+const legacySchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	confirmation_token TEXT,
+	is_confirmed BOOLEAN DEFAULT FALSE,
+	reset_token TEXT,
+	reset_token_expiry DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	notify_on_comment BOOLEAN DEFAULT TRUE
+);
+CREATE TABLE IF NOT EXISTS images (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	file_path TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (image_id) REFERENCES images(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS likes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	image_id INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (image_id) REFERENCES images(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS unique_like ON likes (user_id, image_id);
+`
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(dialect.SQLite.Driver, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// checkApplied runs Up twice and checks that the first run applied every
+// migration and the second none.
+func checkApplied(t *testing.T, db *sql.DB) {
+	t.Helper()
+	all, err := migrations.Load(dialect.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := migrations.Up(db, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(done) != len(all) {
+		t.Errorf("applied %d migrations, want %d", len(done), len(all))
+	}
+
+	done, err = migrations.Up(db, dialect.SQLite)
+	if err != nil || len(done) != 0 {
+		t.Errorf("second Up applied %d migrations, err %v, want none", len(done), err)
+	}
+	statuses, err := migrations.GetStatus(db, dialect.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Errorf("migration %04d_%s not recorded as applied", status.Version, status.Name)
+		}
+	}
+}
+
+func TestUpFreshDatabase(t *testing.T) {
+	db := openSQLite(t)
+	checkApplied(t, db)
+
+	for _, table := range []string{"users", "images", "comments", "likes", "image_renditions", "sessions", "two_factor", "recovery_codes", "identities", "api_tokens", "overlays", "email_outbox", "image_frames"} {
+		var name string
+		err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&name)
+		if err != nil {
+			t.Errorf("table %s: %v", table, err)
+		}
+	}
+}
+
+// TestUpLegacyDatabase upgrades a database created before migrations, with
+// image paths that still include the uploads directory.
+func TestUpLegacyDatabase(t *testing.T) {
+	db := openSQLite(t)
+	if _, err := db.Exec(legacySchema); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		`INSERT INTO users (username, email, password, is_confirmed) VALUES ('alice', 'alice@example.com', 'hash', TRUE)`,
+		`INSERT INTO images (user_id, file_path, created_at) VALUES (1, 'uploads/photo_1.png', '2023-06-01 12:00:00')`,
+		`INSERT INTO comments (image_id, user_id, content) VALUES (1, 1, 'Nice one')`,
+		`INSERT INTO likes (user_id, image_id) VALUES (1, 1)`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	checkApplied(t, db)
+
+	stores := store.NewSQL(db, dialect.SQLite)
+	image, err := stores.Images.GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.FilePath != "photo_1.png" || image.UserID != 1 {
+		t.Errorf("image = %+v, want photo_1.png of user 1", image)
+	}
+	user, err := stores.Users.GetByUsername("alice")
+	if err != nil || !user.IsConfirmed {
+		t.Errorf("user = %+v, %v, want confirmed alice", user, err)
+	}
+
+	images, err := stores.Images.ListPage(user.ID, store.Cursor{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || images[0].Likes != 1 || len(images[0].Comments) != 1 || images[0].Comments[0].Content != "Nice one" {
+		t.Errorf("feed = %+v, want the image with its like and comment", images)
+	}
+}
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	confirmation_token TEXT,
+	is_confirmed BOOLEAN DEFAULT FALSE,
+	reset_token TEXT,
+	reset_token_expiry TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	notify_on_comment BOOLEAN DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS images (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER REFERENCES users(id),
+	file_path TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+	id SERIAL PRIMARY KEY,
+	image_id INTEGER NOT NULL REFERENCES images(id),
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	content TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS likes (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER REFERENCES users(id),
+	image_id INTEGER REFERENCES images(id),
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_like ON likes (user_id, image_id);
+
+CREATE TABLE IF NOT EXISTS image_renditions (
+	id SERIAL PRIMARY KEY,
+	image_id INTEGER NOT NULL REFERENCES images(id),
+	name TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	width INTEGER NOT NULL,
+	UNIQUE (image_id, name)
+);
@@ -0,0 +1,3 @@
+-- Images saved before the storage backend was introduced recorded their path
+-- relative to the working directory; storage keys are relative to the backend.
+UPDATE images SET file_path = substr(file_path, length('uploads/') + 1) WHERE file_path LIKE 'uploads/%';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS two_factor (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id),
+	secret TEXT NOT NULL,
+	enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	last_step BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMPTZ,
+	UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS identities (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	provider TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS identities_user ON identities (user_id);
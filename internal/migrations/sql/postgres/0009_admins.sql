@@ -0,0 +1,3 @@
+-- Admins manage the overlay catalog. Grant the role with
+-- `photo-booth admin grant <username>`.
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- The filter and adjustments applied when the image was saved, as JSON.
+-- Empty if the photo was saved as it was.
+ALTER TABLE images ADD COLUMN IF NOT EXISTS edits TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+-- Photo strips are composed from several captures, which are kept as frames
+-- of the strip in the order they were taken.
+ALTER TABLE images ADD COLUMN IF NOT EXISTS layout TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS image_frames (
+	id SERIAL PRIMARY KEY,
+	image_id INTEGER NOT NULL REFERENCES images(id),
+	position INTEGER NOT NULL,
+	file_path TEXT NOT NULL,
+	UNIQUE (image_id, position)
+);
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	email TEXT NOT NULL UNIQUE,
+	password TEXT NOT NULL,
+	confirmation_token TEXT,
+	is_confirmed BOOLEAN DEFAULT FALSE,
+	reset_token TEXT,
+	reset_token_expiry DATETIME,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	notify_on_comment BOOLEAN DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS images (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	file_path TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (image_id) REFERENCES images(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS likes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	image_id INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (image_id) REFERENCES images(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_like ON likes (user_id, image_id);
+
+CREATE TABLE IF NOT EXISTS image_renditions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	width INTEGER NOT NULL,
+	FOREIGN KEY (image_id) REFERENCES images(id),
+	UNIQUE (image_id, name)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS identities (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	provider TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS identities_user ON identities (user_id);
@@ -0,0 +1,3 @@
+-- When the photo was taken according to its EXIF data. Only recorded when
+-- KEEP_CAPTURE_TIME=true; NULL otherwise.
+ALTER TABLE images ADD COLUMN captured_at DATETIME;
@@ -0,0 +1,13 @@
+-- The overlay catalog. It is filled from static/img/overlays on first start.
+CREATE TABLE IF NOT EXISTS overlays (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	slug TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	category TEXT NOT NULL DEFAULT '',
+	file_path TEXT NOT NULL,
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	sort_order INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS overlays_order ON overlays (sort_order, id);
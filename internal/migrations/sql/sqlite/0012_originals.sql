@@ -0,0 +1,5 @@
+-- The untouched capture the image is rendered from, and the overlay it was
+-- rendered with. Images saved before originals were kept have no
+-- original_path and can't be edited.
+ALTER TABLE images ADD COLUMN original_path TEXT NOT NULL DEFAULT '';
+ALTER TABLE images ADD COLUMN overlay TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- Photo strips are composed from several captures, which are kept as frames
+-- of the strip in the order they were taken.
+ALTER TABLE images ADD COLUMN layout TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS image_frames (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	file_path TEXT NOT NULL,
+	FOREIGN KEY (image_id) REFERENCES images(id),
+	UNIQUE (image_id, position)
+);
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	ScopeRead    = "read"
+	ScopeUpload  = "upload"
+	ScopeComment = "comment"
+	// ScopeAdmin manages the overlay catalog. Only admins can create tokens
+	// with it, and it stops working if the user loses admin rights.
+	ScopeAdmin = "admin"
+)
+
+var Scopes = []string{ScopeRead, ScopeUpload, ScopeComment}
+
+// ScopesFor returns the scopes user can put on a token.
+func ScopesFor(user *User) []string {
+	if user.IsAdmin {
+		return append(Scopes[:len(Scopes):len(Scopes)], ScopeAdmin)
+	}
+	return Scopes
+}
+
+// APIToken is a personal access token. Only the hash of the token is stored.
+type APIToken struct {
+	ID         int
+	UserID     int
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package models
+
+// Edits are the filter and adjustments applied to a photo before its overlay,
+// and the layers drawn on top of it. They are stored with the image as JSON;
+// the zero value changes nothing.
+type Edits struct {
+	Filter string `json:"filter,omitempty"`
+	// Brightness, Contrast and Saturation range from -1 to 1, with 0 leaving
+	// the photo as it is.
+	Brightness float64 `json:"brightness,omitempty"`
+	Contrast   float64 `json:"contrast,omitempty"`
+	Saturation float64 `json:"saturation,omitempty"`
+	// Rotate turns the photo clockwise by 90, 180 or 270 degrees. It is
+	// applied before Crop.
+	Rotate int   `json:"rotate,omitempty"`
+	Crop   *Crop `json:"crop,omitempty"`
+	// Layers are drawn over the overlay in order, so later layers cover
+	// earlier ones.
+	Layers []Layer `json:"layers,omitempty"`
+}
+
+// Crop is a rectangle in fractions of the photo's width and height, so the
+// same crop fits the photo at any resolution.
+type Crop struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+func (e Edits) IsZero() bool {
+	return e.Filter == "" && e.Brightness == 0 && e.Contrast == 0 && e.Saturation == 0 &&
+		e.Rotate == 0 && e.Crop == nil && len(e.Layers) == 0
+}
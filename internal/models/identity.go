@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Identity links a user to an account at an OpenID Connect provider.
+type Identity struct {
+	ID        int
+	UserID    int
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
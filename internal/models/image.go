@@ -1,13 +1,76 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type Image struct {
-	ID        int
-	UserID    int
-	FilePath  string
-	Likes     int
-	CreatedAt time.Time
-	Comments  []Comment
-	IsOwner   bool
+	ID       int
+	UserID   int
+	Username string
+	FilePath string
+	// OriginalPath is the capture as it was uploaded, before the overlay and
+	// edits. FilePath and the renditions are rendered from it.
+	OriginalPath string
+	// Overlay is the slug of the overlay the image was rendered with.
+	Overlay string
+	// Layout is set for images composed from several captures: photo strips,
+	// which keep them as Frames, and animations.
+	Layout       string
+	Frames       []Frame
+	URL          string
+	OriginalURL  string
+	ThumbnailURL string
+	Srcset       string
+	Renditions   []Rendition
+	Likes        int
+	CreatedAt    time.Time
+	CapturedAt   time.Time
+	Edits        Edits
+	Comments     []Comment
+	IsOwner      bool
+}
+
+func (i *Image) ResolveURLs(url func(key string) string) {
+	i.URL = url(i.FilePath)
+	i.ThumbnailURL = i.URL
+	// Originals and frames are private; their links go through the pages
+	// that check the image belongs to the viewer.
+	if i.OriginalPath != "" {
+		i.OriginalURL = fmt.Sprintf("/images/original?image_id=%d", i.ID)
+	}
+
+	for j := range i.Frames {
+		i.Frames[j].URL = fmt.Sprintf("/images/frame?image_id=%d&position=%d", i.ID, i.Frames[j].Position)
+	}
+
+	hasPoster := false
+	for _, rendition := range i.Renditions {
+		hasPoster = hasPoster || rendition.Name == RenditionPoster
+	}
+
+	var srcset []string
+	for j := range i.Renditions {
+		rendition := &i.Renditions[j]
+		rendition.URL = url(rendition.FilePath)
+		// Animations are listed by their still poster frame, so the gallery
+		// grid doesn't play them.
+		if hasPoster && rendition.Name == RenditionOriginal {
+			continue
+		}
+		if rendition.Name == RenditionThumbnail || (rendition.Name == RenditionPoster && i.ThumbnailURL == i.URL) {
+			i.ThumbnailURL = rendition.URL
+		}
+		srcset = append(srcset, fmt.Sprintf("%s %dw", rendition.URL, rendition.Width))
+	}
+	i.Srcset = strings.Join(srcset, ", ")
+}
+
+// Frame is one of the captures a photo strip was composed from.
+type Frame struct {
+	Position int
+	FilePath string
+	URL      string
 }
@@ -0,0 +1,32 @@
+package models
+
+const (
+	LayerText    = "text"
+	LayerSticker = "sticker"
+)
+
+// Layer is a text caption or a sticker placed on a photo. Positions and sizes
+// are fractions of the photo, like Crop, so a layer lands in the same place
+// at any resolution.
+type Layer struct {
+	Type string `json:"type"`
+	// X and Y are the center of the layer.
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+
+	// Text layers are drawn in Font at Size, a fraction of the photo's
+	// height, filled with Color and outlined with StrokeColor. StrokeWidth
+	// is a fraction of Size; 0 draws no outline. Colors are "#rrggbb".
+	Text        string  `json:"text,omitempty"`
+	Font        string  `json:"font,omitempty"`
+	Size        float64 `json:"size,omitempty"`
+	Color       string  `json:"color,omitempty"`
+	StrokeColor string  `json:"stroke_color,omitempty"`
+	StrokeWidth float64 `json:"stroke_width,omitempty"`
+
+	// Sticker layers draw the named sticker Scale times the photo's width
+	// wide, turned Rotation degrees clockwise.
+	Sticker  string  `json:"sticker,omitempty"`
+	Scale    float64 `json:"scale,omitempty"`
+	Rotation float64 `json:"rotation,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Overlay is a frame users can put on their photos. The PNG lives in the
+// storage backend under FilePath.
+type Overlay struct {
+	ID        int
+	Slug      string
+	Name      string
+	Category  string
+	FilePath  string
+	URL       string
+	Enabled   bool
+	SortOrder int
+	CreatedAt time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+const (
+	RenditionThumbnail = "thumbnail"
+	RenditionMedium    = "medium"
+	RenditionOriginal  = "original"
+	// RenditionPoster is the still first frame of an animation.
+	RenditionPoster = "poster"
+)
+
+type Rendition struct {
+	Name     string
+	FilePath string
+	URL      string
+	Width    int
+}
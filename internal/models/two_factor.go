@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type TwoFactor struct {
+	UserID    int
+	Secret    string
+	Enabled   bool
+	LastStep  int64
+	CreatedAt time.Time
+}
@@ -13,4 +13,5 @@ type User struct {
 	ResetTokenExpiry  time.Time
 	CreatedAt         time.Time
 	NotifyOnComment   bool
+	IsAdmin           bool
 }
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"photo-booth.com/internal/imaging"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/storage"
+	"photo-booth.com/internal/store"
+)
+
+// OverlayDir holds the overlays bundled with the app. They are imported into
+// an empty catalog on startup; after that the catalog is the only source.
+const OverlayDir = "static/img/overlays"
+
+var ErrOverlayNotFound = errors.New("overlay not found")
+
+// Slugify turns an overlay name into its slug, e.g. "Film Wide" and
+// "Film-Wide" both become "film-wide".
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// SaveOverlay stores a validated overlay PNG and adds it to the end of the
+// catalog.
+func SaveOverlay(overlays store.OverlayStore, files storage.Storage, overlay *models.Overlay, img image.Image) error {
+	if overlay.Slug == "" {
+		overlay.Slug = Slugify(overlay.Name)
+	}
+	if overlay.Slug == "" {
+		return fmt.Errorf("overlay %q has no usable slug", overlay.Name)
+	}
+	if _, err := overlays.GetBySlug(overlay.Slug); err == nil {
+		return store.ErrDuplicate
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	overlay.FilePath = "overlays/" + overlay.Slug + ".png"
+	if err := files.Put(overlay.FilePath, &buf, "image/png"); err != nil {
+		return err
+	}
+
+	return overlays.Create(overlay)
+}
+
+// SeedOverlays imports the bundled overlays if the catalog is empty. Files
+// whose names slugify to the same slug are only imported once.
+func SeedOverlays(overlays store.OverlayStore, files storage.Storage) error {
+	existing, err := overlays.List(true)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(OverlayDir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".png") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file, err := os.Open(filepath.Join(OverlayDir, name))
+		if err != nil {
+			return err
+		}
+		img, err := imaging.Decode(file)
+		file.Close()
+		if err != nil {
+			log.Printf("Skipping overlay %s: %v", name, err)
+			continue
+		}
+
+		overlay := &models.Overlay{
+			Name:     strings.ReplaceAll(strings.TrimSuffix(name, filepath.Ext(name)), "-", " "),
+			Category: "Frames",
+			Enabled:  true,
+		}
+		err = SaveOverlay(overlays, files, overlay, img)
+		if errors.Is(err, store.ErrDuplicate) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("importing overlay %s: %w", name, err)
+		}
+	}
+
+	log.Printf("Imported bundled overlays into the catalog")
+	return nil
+}
+
+// OverlaySlug returns the slug an overlay name refers to. Names from before
+// the catalog, like "Film Wide.png", still resolve to their slug.
+func OverlaySlug(name string) string {
+	return Slugify(strings.TrimSuffix(name, ".png"))
+}
+
+// LoadOverlay decodes an enabled overlay from the catalog.
+func LoadOverlay(overlays store.OverlayStore, files storage.Storage, name string) (image.Image, error) {
+	overlay, err := overlays.GetBySlug(OverlaySlug(name))
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !overlay.Enabled) {
+		return nil, ErrOverlayNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := files.Get(overlay.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return imaging.Decode(bytes.NewReader(data))
+}
@@ -0,0 +1,26 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Auth holds the limits applied to login and password reset. Limits keyed by
+// account use whatever the client submitted, whether or not the account
+// exists, so they reveal nothing about which accounts are registered.
+type Auth struct {
+	LoginIP      *Limiter
+	LoginAccount *Limiter
+	ResetIP      *Limiter
+	ResetAccount *Limiter
+	Lockout      *Lockout
+}
+
+func NewAuth(store Store) *Auth {
+	return &Auth{
+		LoginIP:      NewLimiter(store, "login-ip", Limit{Burst: 20, Every: 6 * time.Second}),
+		LoginAccount: NewLimiter(store, "login-account", Limit{Burst: 10, Every: 30 * time.Second}),
+		ResetIP:      NewLimiter(store, "reset-ip", Limit{Burst: 5, Every: time.Minute}),
+		ResetAccount: NewLimiter(store, "reset-account", Limit{Burst: 3, Every: 20 * time.Minute}),
+		Lockout:      NewLockout(store),
+	}
+}
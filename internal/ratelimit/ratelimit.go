@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"log"
+	"time"
+)
+
+// Limit describes a token bucket holding up to Burst tokens, refilled with one
+// token every Every.
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
+
+// Store keeps bucket and failure state. MemoryStore is enough for a single
+// instance; several instances need a shared implementation.
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (ok bool, retryAfter time.Duration)
+	AddFailure(key string, now time.Time) int
+	Failures(key string) (count int, last time.Time)
+	ClearFailures(key string)
+}
+
+type Limiter struct {
+	store  Store
+	prefix string
+	limit  Limit
+}
+
+func NewLimiter(store Store, prefix string, limit Limit) *Limiter {
+	return &Limiter{store: store, prefix: prefix, limit: limit}
+}
+
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.store.Take(l.prefix+":"+key, l.limit, time.Now())
+}
+
+// Lockout locks a key after Threshold consecutive failures. Each further
+// failure doubles the lock, starting at Base and capped at Max. Failures older
+// than Window are forgotten.
+type Lockout struct {
+	store     Store
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+func NewLockout(store Store) *Lockout {
+	return &Lockout{
+		store:     store,
+		Threshold: 5,
+		Base:      time.Minute,
+		Max:       time.Hour,
+		Window:    24 * time.Hour,
+	}
+}
+
+// Check returns how long key remains locked, or zero if it is not locked.
+func (l *Lockout) Check(key string) time.Duration {
+	now := time.Now()
+	count, last := l.store.Failures("lockout:" + key)
+	if count < l.Threshold {
+		return 0
+	}
+	if remaining := last.Add(l.duration(count)).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func (l *Lockout) Fail(key string) {
+	now := time.Now()
+	if _, last := l.store.Failures("lockout:" + key); !last.IsZero() && now.Sub(last) > l.Window {
+		l.store.ClearFailures("lockout:" + key)
+	}
+	count := l.store.AddFailure("lockout:"+key, now)
+	if count >= l.Threshold {
+		log.Printf("Locking %q for %s after %d failed attempts", key, l.duration(count), count)
+	}
+}
+
+func (l *Lockout) Reset(key string) {
+	l.store.ClearFailures("lockout:" + key)
+}
+
+func (l *Lockout) duration(count int) time.Duration {
+	d := l.Base
+	for i := l.Threshold; i < count && d < l.Max; i++ {
+		d *= 2
+	}
+	if d > l.Max {
+		d = l.Max
+	}
+	return d
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Burst: 3, Every: 10 * time.Second}
+	start := time.Date(2024, 5, 24, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < limit.Burst; i++ {
+		if ok, _ := store.Take("a", limit, start); !ok {
+			t.Fatalf("request %d of the burst was refused", i+1)
+		}
+	}
+	ok, retryAfter := store.Take("a", limit, start)
+	if ok || retryAfter != 10*time.Second {
+		t.Errorf("request after the burst: ok = %v, retry after %s, want refused for 10s", ok, retryAfter)
+	}
+	if ok, _ := store.Take("b", limit, start); !ok {
+		t.Error("another key shares the bucket")
+	}
+
+	if ok, retryAfter := store.Take("a", limit, start.Add(4*time.Second)); ok || retryAfter != 6*time.Second {
+		t.Errorf("partly refilled: ok = %v, retry after %s, want refused for 6s", ok, retryAfter)
+	}
+	if ok, _ := store.Take("a", limit, start.Add(10*time.Second)); !ok {
+		t.Error("refilled token was refused")
+	}
+
+	// A bucket never holds more than Burst tokens, however long it rests.
+	later := start.Add(time.Hour)
+	for i := 0; i < limit.Burst; i++ {
+		if ok, _ := store.Take("a", limit, later); !ok {
+			t.Fatalf("request %d after resting was refused", i+1)
+		}
+	}
+	if ok, _ := store.Take("a", limit, later); ok {
+		t.Error("bucket refilled beyond its burst")
+	}
+}
+
+func TestLockoutDuration(t *testing.T) {
+	lockout := NewLockout(NewMemoryStore())
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{10, 32 * time.Minute},
+		{11, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := lockout.duration(tt.count); got != tt.want {
+			t.Errorf("duration(%d) = %s, want %s", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	store := NewMemoryStore()
+	lockout := NewLockout(store)
+
+	for i := 1; i < lockout.Threshold; i++ {
+		lockout.Fail("alice")
+		if locked := lockout.Check("alice"); locked != 0 {
+			t.Fatalf("locked for %s after %d failures", locked, i)
+		}
+	}
+	lockout.Fail("alice")
+	if locked := lockout.Check("alice"); locked <= 0 || locked > time.Minute {
+		t.Errorf("locked for %s after %d failures, want up to a minute", locked, lockout.Threshold)
+	}
+	if locked := lockout.Check("bob"); locked != 0 {
+		t.Errorf("another account is locked for %s", locked)
+	}
+
+	lockout.Reset("alice")
+	if locked := lockout.Check("alice"); locked != 0 {
+		t.Errorf("locked for %s after a reset", locked)
+	}
+
+	// The lock runs from the last failure: six failures 90 seconds ago lock
+	// for two minutes, half a minute of which is left.
+	past := time.Now().Add(-90 * time.Second)
+	for i := 0; i < 6; i++ {
+		store.AddFailure("lockout:carol", past)
+	}
+	if locked := lockout.Check("carol"); locked <= 25*time.Second || locked > 30*time.Second {
+		t.Errorf("locked for %s, want about 30s", locked)
+	}
+
+	// Failures older than the window are forgotten by the next failure.
+	for i := 0; i < 10; i++ {
+		store.AddFailure("lockout:dave", time.Now().Add(-2*lockout.Window))
+	}
+	lockout.Fail("dave")
+	if count, _ := store.Failures("lockout:dave"); count != 1 {
+		t.Errorf("%d failures counted, want the stale ones forgotten", count)
+	}
+}
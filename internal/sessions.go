@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"photo-booth.com/internal/models"
+	"photo-booth.com/internal/store"
+)
+
+const (
+	sessionMaxAge          = 30 * 24 * time.Hour
+	anonymousSessionMaxAge = 24 * time.Hour
+	sessionTouchInterval   = time.Minute
+	sessionPruneInterval   = time.Hour
+)
+
+// SessionStore is a gorilla sessions.Store that keeps session values in the
+// database. The cookie only carries a random token, so sessions can be listed
+// and revoked server-side. The token is replaced whenever the user signed in
+// to the session changes.
+type SessionStore struct {
+	store   store.SessionStore
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+}
+
+func NewSessionStore(sessionStore store.SessionStore, keyPairs ...[]byte) *SessionStore {
+	return &SessionStore{
+		store:  sessionStore,
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   int(sessionMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+	}
+}
+
+func (s *SessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *SessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+
+	record, token, err := s.load(r, name)
+	if err != nil || record == nil {
+		return session, err
+	}
+
+	if err := (securecookie.GobEncoder{}).Deserialize(decodeData(record.Data), &session.Values); err != nil {
+		log.Printf("Error decoding session %d: %v", record.ID, err)
+		return session, nil
+	}
+	session.ID = token
+	session.IsNew = false
+
+	ip, userAgent := ClientIP(r), r.UserAgent()
+	if time.Since(record.LastSeenAt) > sessionTouchInterval || record.IP != ip || record.UserAgent != userAgent {
+		if err := s.store.Touch(record.ID, time.Now(), ip, userAgent); err != nil {
+			log.Printf("Error updating session %d: %v", record.ID, err)
+		}
+	}
+	return session, nil
+}
+
+func (s *SessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	var record *models.Session
+	if session.ID != "" {
+		found, err := s.store.GetByTokenHash(hashSessionToken(session.ID))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		record = found
+	}
+
+	if session.Options.MaxAge < 0 {
+		if record != nil {
+			if err := s.store.Delete(record.ID); err != nil {
+				return err
+			}
+		}
+		session.ID = ""
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	data, err := securecookie.GobEncoder{}.Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+
+	userID := 0
+	if session.Values["authenticated"] == true {
+		userID, _ = session.Values["user_id"].(int)
+	}
+
+	now := time.Now()
+	maxAge := time.Duration(session.Options.MaxAge) * time.Second
+	if userID == 0 && maxAge > anonymousSessionMaxAge {
+		maxAge = anonymousSessionMaxAge
+	}
+
+	if record != nil && record.UserID == userID {
+		record.Data = encodeData(data)
+		record.LastSeenAt = now
+		record.ExpiresAt = now.Add(maxAge)
+		if err := s.store.Update(record); err != nil {
+			return err
+		}
+	} else {
+		if record != nil {
+			if err := s.store.Delete(record.ID); err != nil {
+				return err
+			}
+		}
+
+		token := newSessionToken()
+		record = &models.Session{
+			TokenHash:  hashSessionToken(token),
+			UserID:     userID,
+			Data:       encodeData(data),
+			UserAgent:  r.UserAgent(),
+			IP:         ClientIP(r),
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(maxAge),
+		}
+		if err := s.store.Create(record); err != nil {
+			return err
+		}
+		session.ID = token
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// CurrentID returns the database ID of the session attached to r, or 0 if
+// the request has no stored session.
+func (s *SessionStore) CurrentID(r *http.Request) int {
+	session, _ := s.Get(r, "session")
+	if session.ID == "" {
+		return 0
+	}
+	record, err := s.store.GetByTokenHash(hashSessionToken(session.ID))
+	if err != nil {
+		return 0
+	}
+	return record.ID
+}
+
+// Run deletes expired sessions every sessionPruneInterval until ctx is
+// cancelled.
+func (s *SessionStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(sessionPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := s.store.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Error deleting expired sessions: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired sessions", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SessionStore) load(r *http.Request, name string) (*models.Session, string, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return nil, "", nil
+	}
+
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.Codecs...); err != nil {
+		return nil, "", nil
+	}
+
+	record, err := s.store.GetByTokenHash(hashSessionToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, "", nil
+	}
+	return record, token, err
+}
+
+func newSessionToken() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func encodeData(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func decodeData(data string) []byte {
+	decoded, _ := base64.StdEncoding.DecodeString(data)
+	return decoded
+}
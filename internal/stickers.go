@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"photo-booth.com/internal/imaging"
+)
+
+// StickerDir holds the sticker PNGs that can be placed on photos as layers.
+// A sticker is named after its file, without the extension.
+const StickerDir = "static/img/stickers"
+
+var ErrStickerNotFound = errors.New("sticker not found")
+
+// ListStickers returns the names of the bundled stickers.
+func ListStickers() ([]string, error) {
+	entries, err := os.ReadDir(StickerDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".png")
+		if !entry.IsDir() && name != entry.Name() && Slugify(name) == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// StickerURL is where the camera page loads a sticker from.
+func StickerURL(name string) string {
+	return "/" + StickerDir + "/" + name + ".png"
+}
+
+// LoadSticker decodes a bundled sticker. Names are slugs, so they can't
+// point outside StickerDir.
+func LoadSticker(name string) (image.Image, error) {
+	if name == "" || Slugify(name) != name {
+		return nil, ErrStickerNotFound
+	}
+
+	file, err := os.Open(filepath.Join(StickerDir, name+".png"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrStickerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return imaging.Decode(file)
+}
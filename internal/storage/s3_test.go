@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "minioadmin"
+	testSecretKey = "minio-secret"
+	testRegion    = "eu-central-1"
+	testBucket    = "photo-booth"
+)
+
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+type storedObject struct {
+	body        []byte
+	contentType string
+}
+
+// s3Stub is a minimal S3 stand-in that keeps objects in memory and checks
+// every request's SigV4 signature the way S3 and MinIO do.
+type s3Stub struct {
+	secret  string
+	mu      sync.Mutex
+	objects map[string]storedObject
+}
+
+func newS3Stub(t *testing.T) (*s3Stub, *httptest.Server) {
+	stub := &s3Stub{secret: testSecretKey, objects: map[string]storedObject{}}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reason := s.verify(r, body); reason != "" {
+		http.Error(w, "SignatureDoesNotMatch: "+reason, http.StatusForbidden)
+		return
+	}
+
+	prefix := "/" + testBucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = storedObject{body: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		object, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Write(object.body)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *s3Stub) object(key string) storedObject {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[key]
+}
+
+// verify recomputes the signature from the request as received and returns
+// why it doesn't match, or "" if it does.
+func (s *s3Stub) verify(r *http.Request, body []byte) string {
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		return "malformed Authorization header"
+	}
+	accessKey, date, region, signedHeaders, signature := match[1], match[2], match[3], match[4], match[5]
+	if accessKey != testAccessKey || region != testRegion {
+		return "wrong credential scope"
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, date) || time.Since(signedAt).Abs() > 15*time.Minute {
+		return "bad X-Amz-Date"
+	}
+
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	if r.Header.Get("X-Amz-Content-Sha256") != payloadHash {
+		return "payload hash does not match body"
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+signedHeaders+";", ";"+required+";") {
+			return required + " is not signed"
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{r.Method, r.URL.EscapedPath(), r.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, date + "/" + region + "/s3/aws4_request", hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + s.secret)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = testHMAC(key, part)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(testHMAC(key, stringToSign))), []byte(signature)) {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+func testHMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func newTestS3(t *testing.T, endpoint, secret string) *S3 {
+	s, err := NewS3(S3Config{
+		Endpoint:  endpoint,
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: secret,
+		PublicURL: "https://cdn.example.com/photos/",
+	})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	stub, server := newS3Stub(t)
+	s := newTestS3(t, server.URL, testSecretKey)
+
+	for _, key := range []string{"photo_1.png", "frames/photo_2.jpg", "overlays/party hat+1.png"} {
+		t.Run(key, func(t *testing.T) {
+			data := []byte("image data for " + key)
+			if err := s.Put(key, bytes.NewReader(data), "image/png"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if got := stub.object(key).contentType; got != "image/png" {
+				t.Errorf("stored content type = %q, want image/png", got)
+			}
+
+			body, err := s.Get(key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			got, _ := io.ReadAll(body)
+			body.Close()
+			if !bytes.Equal(got, data) {
+				t.Errorf("Get returned %q, want %q", got, data)
+			}
+
+			if err := s.Delete(key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := s.Get(key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3DeleteMissing(t *testing.T) {
+	_, server := newS3Stub(t)
+	s := newTestS3(t, server.URL, testSecretKey)
+
+	if err := s.Delete("missing.png"); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestS3WrongSecret(t *testing.T) {
+	_, server := newS3Stub(t)
+	s := newTestS3(t, server.URL, "not-the-secret")
+
+	err := s.Put("photo_1.png", strings.NewReader("data"), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put with the wrong secret: err = %v, want a 403 error", err)
+	}
+	if _, err := s.Get("photo_1.png"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get with the wrong secret: err = %v, want a 403 error", err)
+	}
+}
+
+func TestS3SignatureHeaders(t *testing.T) {
+	s := newTestS3(t, "http://localhost:9000", testSecretKey)
+	req := httptest.NewRequest(http.MethodPut, "http://localhost:9000/photo-booth/photo_1.png", nil)
+	now := time.Date(2024, 5, 24, 13, 4, 5, 0, time.UTC)
+	s.sign(req, []byte("data"), now)
+
+	if got := req.Header.Get("X-Amz-Date"); got != "20240524T130405Z" {
+		t.Errorf("X-Amz-Date = %q", got)
+	}
+	sum := sha256.Sum256([]byte("data"))
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("X-Amz-Content-Sha256 = %q", got)
+	}
+	match := authorizationPattern.FindStringSubmatch(req.Header.Get("Authorization"))
+	if match == nil {
+		t.Fatalf("Authorization = %q", req.Header.Get("Authorization"))
+	}
+	if match[1] != testAccessKey || match[2] != "20240524" || match[3] != testRegion || match[4] != "host;x-amz-content-sha256;x-amz-date" {
+		t.Errorf("Authorization = %q", req.Header.Get("Authorization"))
+	}
+}
+
+func TestS3URL(t *testing.T) {
+	s := newTestS3(t, "http://localhost:9000", testSecretKey)
+	if got := s.URL("frames/photo_1.jpg"); got != "https://cdn.example.com/photos/frames/photo_1.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"photo-booth.com/internal/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in the newest-first image feed. Pages continue
+// strictly after the image it was taken from, so uploads made in the
+// meantime never shift the following pages.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+func CursorFor(image models.Image) Cursor {
+	return Cursor{CreatedAt: image.CreatedAt, ID: image.ID}
+}
+
+func (c Cursor) IsZero() bool {
+	return c.ID == 0
+}
+
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(token string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	createdAtStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{CreatedAt: createdAt, ID: id}, nil
+}
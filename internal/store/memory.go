@@ -0,0 +1,966 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"photo-booth.com/internal/models"
+)
+
+// NewMemory returns stores backed by in-process maps. It mirrors the SQLite
+// behaviour closely enough for handler tests and keeps no state on disk.
+func NewMemory() Stores {
+	m := &memory{
+		users:      map[int]*models.User{},
+		images:     map[int]*models.Image{},
+		renditions: map[int][]models.Rendition{},
+		frames:     map[int][]models.Frame{},
+		likes:      map[[2]int]time.Time{},
+		outbox:     map[int]*models.OutboxEmail{},
+		sessions:   map[int]*models.Session{},
+		twoFactor:  map[int]*models.TwoFactor{},
+		recovery:   map[int]map[string]bool{},
+		identities: map[int]*models.Identity{},
+		apiTokens:  map[int]*models.APIToken{},
+		overlays:   map[int]*models.Overlay{},
+	}
+	return Stores{
+		Users:      &memoryUsers{m},
+		Images:     &memoryImages{m},
+		Comments:   &memoryComments{m},
+		Likes:      &memoryLikes{m},
+		Outbox:     &memoryOutbox{m},
+		Sessions:   &memorySessions{m},
+		TwoFactor:  &memoryTwoFactor{m},
+		Identities: &memoryIdentities{m},
+		APITokens:  &memoryAPITokens{m},
+		Overlays:   &memoryOverlays{m},
+	}
+}
+
+type memory struct {
+	mu         sync.Mutex
+	nextID     int
+	users      map[int]*models.User
+	images     map[int]*models.Image
+	renditions map[int][]models.Rendition
+	frames     map[int][]models.Frame
+	comments   []models.Comment
+	likes      map[[2]int]time.Time
+	outbox     map[int]*models.OutboxEmail
+	sessions   map[int]*models.Session
+	twoFactor  map[int]*models.TwoFactor
+	recovery   map[int]map[string]bool
+	identities map[int]*models.Identity
+	apiTokens  map[int]*models.APIToken
+	overlays   map[int]*models.Overlay
+}
+
+func (m *memory) id() int {
+	m.nextID++
+	return m.nextID
+}
+
+type memoryUsers struct {
+	*memory
+}
+
+func (s *memoryUsers) Create(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+
+	user.ID = s.id()
+	user.CreatedAt = time.Now()
+	user.NotifyOnComment = true
+	stored := *user
+	s.users[user.ID] = &stored
+	return nil
+}
+
+func (s *memoryUsers) find(match func(*models.User) bool) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if match(user) {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUsers) GetByID(userID int) (*models.User, error) {
+	return s.find(func(u *models.User) bool { return u.ID == userID })
+}
+
+func (s *memoryUsers) GetByUsername(username string) (*models.User, error) {
+	return s.find(func(u *models.User) bool { return u.Username == username })
+}
+
+func (s *memoryUsers) GetByEmail(email string) (*models.User, error) {
+	return s.find(func(u *models.User) bool { return u.Email == email })
+}
+
+func (s *memoryUsers) GetByResetToken(token string) (*models.User, error) {
+	now := time.Now()
+	return s.find(func(u *models.User) bool {
+		return u.ResetToken != "" && u.ResetToken == token && u.ResetTokenExpiry.After(now)
+	})
+}
+
+func (s *memoryUsers) Confirm(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.ConfirmationToken != "" && user.ConfirmationToken == token {
+			user.IsConfirmed = true
+			user.ConfirmationToken = ""
+			return nil
+		}
+	}
+	return ErrInvalidToken
+}
+
+func (s *memoryUsers) UpdateProfile(userID int, username, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil
+	}
+	for _, other := range s.users {
+		if other.ID != userID && ((username != "" && other.Username == username) || (email != "" && other.Email == email)) {
+			return ErrDuplicate
+		}
+	}
+
+	if username != "" {
+		user.Username = username
+	}
+	if email != "" {
+		user.Email = email
+	}
+	return nil
+}
+
+func (s *memoryUsers) UpdatePassword(userID int, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userID]; ok {
+		user.Password = password
+		user.ResetToken = ""
+		user.ResetTokenExpiry = time.Time{}
+	}
+	return nil
+}
+
+func (s *memoryUsers) SaveResetToken(userID int, token string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userID]; ok {
+		user.ResetToken = token
+		user.ResetTokenExpiry = expiry
+	}
+	return nil
+}
+
+func (s *memoryUsers) SetAdmin(userID int, admin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.IsAdmin = admin
+	return nil
+}
+
+type memoryImages struct {
+	*memory
+}
+
+func (s *memoryImages) Create(image *models.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	image.ID = s.id()
+	image.CreatedAt = time.Now().UTC()
+	s.images[image.ID] = &models.Image{
+		ID:           image.ID,
+		UserID:       image.UserID,
+		FilePath:     image.FilePath,
+		OriginalPath: image.OriginalPath,
+		Overlay:      image.Overlay,
+		Layout:       image.Layout,
+		CreatedAt:    image.CreatedAt,
+		CapturedAt:   image.CapturedAt,
+		Edits:        image.Edits,
+	}
+	s.renditions[image.ID] = append([]models.Rendition(nil), image.Renditions...)
+	s.frames[image.ID] = append([]models.Frame(nil), image.Frames...)
+	return nil
+}
+
+func (s *memoryImages) UpdateRender(image *models.Image) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.images[image.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.FilePath = image.FilePath
+	stored.Overlay = image.Overlay
+	stored.Edits = image.Edits
+	s.renditions[image.ID] = append([]models.Rendition(nil), image.Renditions...)
+	return nil
+}
+
+func (s *memoryImages) GetByID(imageID int) (*models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	image, ok := s.images[imageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &models.Image{
+		ID:           image.ID,
+		UserID:       image.UserID,
+		FilePath:     image.FilePath,
+		OriginalPath: image.OriginalPath,
+		Overlay:      image.Overlay,
+		Layout:       image.Layout,
+		Edits:        image.Edits,
+	}, nil
+}
+
+func (s *memoryImages) GetAuthor(imageID int) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	image, ok := s.images[imageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user, ok := s.users[image.UserID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &models.User{ID: user.ID, Username: user.Username, Email: user.Email, NotifyOnComment: user.NotifyOnComment}, nil
+}
+
+// sorted returns the images newest first; the caller must hold the lock.
+func (s *memoryImages) sorted(match func(*models.Image) bool) []models.Image {
+	var images []models.Image
+	for _, image := range s.images {
+		if match(image) {
+			images = append(images, *image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].CreatedAt.Equal(images[j].CreatedAt) {
+			return images[i].ID > images[j].ID
+		}
+		return images[i].CreatedAt.After(images[j].CreatedAt)
+	})
+	return images
+}
+
+func first(images []models.Image, limit int) []models.Image {
+	if limit < len(images) {
+		images = images[:limit]
+	}
+	return images
+}
+
+func (s *memoryImages) ListPage(viewerID int, after Cursor, limit int) ([]models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed := s.sorted(func(i *models.Image) bool {
+		if after.IsZero() {
+			return true
+		}
+		return i.CreatedAt.Before(after.CreatedAt) || (i.CreatedAt.Equal(after.CreatedAt) && i.ID < after.ID)
+	})
+
+	images := []models.Image{}
+	for _, image := range first(feed, limit) {
+		images = append(images, s.detailed(viewerID, image))
+	}
+	return images, nil
+}
+
+func (s *memoryImages) Get(viewerID, imageID int) (*models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	image, ok := s.images[imageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	detailed := s.detailed(viewerID, *image)
+	return &detailed, nil
+}
+
+// detailed fills in what ListPage returns beyond the image row; the caller
+// must hold the lock.
+func (s *memoryImages) detailed(viewerID int, image models.Image) models.Image {
+	image.IsOwner = image.UserID == viewerID
+	if user, ok := s.users[image.UserID]; ok {
+		image.Username = user.Username
+	}
+	image.Renditions = s.renditionsFor(image.ID)
+	image.Frames = append([]models.Frame(nil), s.frames[image.ID]...)
+	image.Comments = s.commentsFor(image.ID)
+	for key := range s.likes {
+		if key[1] == image.ID {
+			image.Likes++
+		}
+	}
+	return image
+}
+
+func (s *memoryImages) ListRecentByUser(userID, limit int) ([]models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var images []models.Image
+	for _, image := range first(s.sorted(func(i *models.Image) bool { return i.UserID == userID }), limit) {
+		image.Renditions = s.renditionsFor(image.ID)
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func (s *memoryImages) Renditions(imageID int) ([]models.Rendition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.renditionsFor(imageID), nil
+}
+
+func (m *memory) renditionsFor(imageID int) []models.Rendition {
+	renditions := append([]models.Rendition{}, m.renditions[imageID]...)
+	sort.Slice(renditions, func(i, j int) bool { return renditions[i].Width < renditions[j].Width })
+	return renditions
+}
+
+func (s *memoryImages) Frames(imageID int) ([]models.Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]models.Frame{}, s.frames[imageID]...), nil
+}
+
+func (s *memoryImages) Delete(imageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.images, imageID)
+	delete(s.renditions, imageID)
+	delete(s.frames, imageID)
+	return nil
+}
+
+type memoryComments struct {
+	*memory
+}
+
+func (s *memoryComments) Add(imageID, userID int, content string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment := models.Comment{ID: s.id(), ImageID: imageID, UserID: userID, Content: content, CreatedAt: time.Now()}
+	if user, ok := s.users[userID]; ok {
+		comment.Username = user.Username
+	}
+	s.comments = append(s.comments, comment)
+	return comment.ID, nil
+}
+
+func (s *memoryComments) Get(commentID int) (*models.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, comment := range s.comments {
+		if comment.ID == commentID {
+			return &comment, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryComments) Delete(commentID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, comment := range s.comments {
+		if comment.ID == commentID {
+			s.comments = append(s.comments[:i], s.comments[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memoryComments) ListByImage(imageID int) ([]models.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commentsFor(imageID), nil
+}
+
+func (m *memory) commentsFor(imageID int) []models.Comment {
+	comments := []models.Comment{}
+	for _, comment := range m.comments {
+		if comment.ImageID == imageID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments
+}
+
+type memoryLikes struct {
+	*memory
+}
+
+func (s *memoryLikes) Add(userID, imageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]int{userID, imageID}
+	if _, ok := s.likes[key]; ok {
+		return ErrLikeExists
+	}
+	s.likes[key] = time.Now()
+	return nil
+}
+
+func (s *memoryLikes) Remove(userID, imageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]int{userID, imageID}
+	if _, ok := s.likes[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.likes, key)
+	return nil
+}
+
+type memoryOutbox struct {
+	*memory
+}
+
+func (s *memoryOutbox) Enqueue(email *models.OutboxEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	email.ID = s.id()
+	email.Status = models.OutboxPending
+	email.CreatedAt = time.Now().UTC()
+	if email.NextAttemptAt.IsZero() {
+		email.NextAttemptAt = email.CreatedAt
+	}
+	stored := *email
+	s.outbox[email.ID] = &stored
+	return nil
+}
+
+func (s *memoryOutbox) Claim(now, leaseUntil time.Time, limit int) ([]models.OutboxEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*models.OutboxEmail
+	for _, email := range s.outbox {
+		if email.Status == models.OutboxPending && !email.NextAttemptAt.After(now) {
+			due = append(due, email)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].ID < due[j].ID
+		}
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	claimed := []models.OutboxEmail{}
+	for _, email := range due {
+		if len(claimed) == limit {
+			break
+		}
+		email.Attempts++
+		email.NextAttemptAt = leaseUntil
+		claimed = append(claimed, *email)
+	}
+	return claimed, nil
+}
+
+func (s *memoryOutbox) MarkSent(emailID int, sentAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if email, ok := s.outbox[emailID]; ok {
+		email.Status = models.OutboxSent
+		email.LastError = ""
+	}
+	return nil
+}
+
+func (s *memoryOutbox) MarkFailed(emailID int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if email, ok := s.outbox[emailID]; ok {
+		email.LastError = lastError
+		email.NextAttemptAt = nextAttemptAt
+		if dead {
+			email.Status = models.OutboxDead
+		}
+	}
+	return nil
+}
+
+type memorySessions struct {
+	*memory
+}
+
+func (s *memorySessions) Create(session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.ID = s.id()
+	stored := *session
+	s.sessions[session.ID] = &stored
+	return nil
+}
+
+func (s *memorySessions) GetByTokenHash(tokenHash string) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, session := range s.sessions {
+		if session.TokenHash == tokenHash && session.ExpiresAt.After(now) {
+			found := *session
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memorySessions) Update(session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.sessions[session.ID]; ok {
+		stored.UserID = session.UserID
+		stored.Data = session.Data
+		stored.LastSeenAt = session.LastSeenAt
+		stored.ExpiresAt = session.ExpiresAt
+	}
+	return nil
+}
+
+func (s *memorySessions) Touch(sessionID int, lastSeenAt time.Time, ip, userAgent string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.sessions[sessionID]; ok {
+		stored.LastSeenAt = lastSeenAt
+		stored.IP = ip
+		stored.UserAgent = userAgent
+	}
+	return nil
+}
+
+func (s *memorySessions) ListByUser(userID int) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (s *memorySessions) Delete(sessionID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *memorySessions) DeleteForUser(userID, sessionID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok || session.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *memorySessions) DeleteAllForUser(userID, exceptID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID && id != exceptID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (s *memorySessions) DeleteExpired(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, session := range s.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(s.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+type memoryTwoFactor struct {
+	*memory
+}
+
+func (s *memoryTwoFactor) Get(userID int) (*models.TwoFactor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, ok := s.twoFactor[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *twoFactor
+	return &found, nil
+}
+
+func (s *memoryTwoFactor) SavePending(userID int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.twoFactor[userID]; ok && existing.Enabled {
+		return ErrDuplicate
+	}
+	s.twoFactor[userID] = &models.TwoFactor{UserID: userID, Secret: secret, CreatedAt: time.Now().UTC()}
+	return nil
+}
+
+func (s *memoryTwoFactor) Enable(userID int, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, ok := s.twoFactor[userID]
+	if !ok || twoFactor.Enabled {
+		return ErrNotFound
+	}
+	twoFactor.Enabled = true
+
+	codes := map[string]bool{}
+	for _, codeHash := range recoveryCodeHashes {
+		codes[codeHash] = false
+	}
+	s.recovery[userID] = codes
+	return nil
+}
+
+func (s *memoryTwoFactor) Disable(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.twoFactor, userID)
+	delete(s.recovery, userID)
+	return nil
+}
+
+func (s *memoryTwoFactor) UseStep(userID int, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, ok := s.twoFactor[userID]
+	if !ok || twoFactor.LastStep >= step {
+		return ErrInvalidToken
+	}
+	twoFactor.LastStep = step
+	return nil
+}
+
+func (s *memoryTwoFactor) UseRecoveryCode(userID int, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used, ok := s.recovery[userID][codeHash]
+	if !ok || used {
+		return ErrInvalidToken
+	}
+	s.recovery[userID][codeHash] = true
+	return nil
+}
+
+func (s *memoryTwoFactor) CountRecoveryCodes(userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, used := range s.recovery[userID] {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryIdentities struct {
+	*memory
+}
+
+func (s *memoryIdentities) Get(provider, subject string) (*models.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			found := *identity
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryIdentities) Create(identity *models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return ErrDuplicate
+		}
+	}
+
+	identity.ID = s.id()
+	identity.CreatedAt = time.Now().UTC()
+	stored := *identity
+	s.identities[identity.ID] = &stored
+	return nil
+}
+
+func (s *memoryIdentities) ListByUser(userID int) ([]models.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identities := []models.Identity{}
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, *identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+	return identities, nil
+}
+
+func (s *memoryIdentities) Delete(userID, identityID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[identityID]
+	if !ok || identity.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.identities, identityID)
+	return nil
+}
+
+type memoryAPITokens struct {
+	*memory
+}
+
+func (s *memoryAPITokens) Create(token *models.APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ID = s.id()
+	token.CreatedAt = time.Now().UTC()
+	stored := *token
+	s.apiTokens[token.ID] = &stored
+	return nil
+}
+
+func (s *memoryAPITokens) GetByHash(tokenHash string) (*models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.apiTokens {
+		if token.TokenHash == tokenHash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryAPITokens) ListByUser(userID int) ([]models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []models.APIToken{}
+	for _, token := range s.apiTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, *token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (s *memoryAPITokens) Touch(tokenID int, lastUsedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, ok := s.apiTokens[tokenID]; ok {
+		token.LastUsedAt = lastUsedAt
+	}
+	return nil
+}
+
+func (s *memoryAPITokens) Delete(userID, tokenID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.apiTokens[tokenID]
+	if !ok || token.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.apiTokens, tokenID)
+	return nil
+}
+
+type memoryOverlays struct {
+	*memory
+}
+
+func (s *memoryOverlays) Create(overlay *models.Overlay) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overlay.SortOrder = 0
+	for _, existing := range s.overlays {
+		if existing.Slug == overlay.Slug {
+			return ErrDuplicate
+		}
+		if existing.SortOrder >= overlay.SortOrder {
+			overlay.SortOrder = existing.SortOrder + 1
+		}
+	}
+
+	overlay.ID = s.id()
+	overlay.CreatedAt = time.Now().UTC()
+	stored := *overlay
+	s.overlays[overlay.ID] = &stored
+	return nil
+}
+
+func (s *memoryOverlays) bySlug(slug string) *models.Overlay {
+	for _, overlay := range s.overlays {
+		if overlay.Slug == slug {
+			return overlay
+		}
+	}
+	return nil
+}
+
+func (s *memoryOverlays) GetBySlug(slug string) (*models.Overlay, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overlay := s.bySlug(slug)
+	if overlay == nil {
+		return nil, ErrNotFound
+	}
+	found := *overlay
+	return &found, nil
+}
+
+func (s *memoryOverlays) List(includeDisabled bool) ([]models.Overlay, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overlays := []models.Overlay{}
+	for _, overlay := range s.overlays {
+		if overlay.Enabled || includeDisabled {
+			overlays = append(overlays, *overlay)
+		}
+	}
+	sort.Slice(overlays, func(i, j int) bool {
+		if overlays[i].SortOrder != overlays[j].SortOrder {
+			return overlays[i].SortOrder < overlays[j].SortOrder
+		}
+		return overlays[i].ID < overlays[j].ID
+	})
+	return overlays, nil
+}
+
+func (s *memoryOverlays) SetEnabled(slug string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overlay := s.bySlug(slug)
+	if overlay == nil {
+		return ErrNotFound
+	}
+	overlay.Enabled = enabled
+	return nil
+}
+
+func (s *memoryOverlays) Reorder(slugs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, slug := range slugs {
+		if s.bySlug(slug) == nil {
+			return ErrNotFound
+		}
+	}
+	for _, overlay := range s.overlays {
+		overlay.SortOrder += len(slugs)
+	}
+	for i, slug := range slugs {
+		s.bySlug(slug).SortOrder = i
+	}
+	return nil
+}
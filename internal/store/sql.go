@@ -0,0 +1,1251 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"photo-booth.com/internal/dialect"
+	"photo-booth.com/internal/models"
+)
+
+func NewSQL(db *sql.DB, d dialect.Dialect) Stores {
+	base := &sqlDB{db: db, dialect: d}
+	return Stores{
+		Users:      &sqlUsers{base},
+		Images:     &sqlImages{base},
+		Comments:   &sqlComments{base},
+		Likes:      &sqlLikes{base},
+		Outbox:     &sqlOutbox{base},
+		Sessions:   &sqlSessions{base},
+		TwoFactor:  &sqlTwoFactor{base},
+		Identities: &sqlIdentities{base},
+		APITokens:  &sqlAPITokens{base},
+		Overlays:   &sqlOverlays{base},
+	}
+}
+
+type sqlDB struct {
+	db      *sql.DB
+	dialect dialect.Dialect
+}
+
+func (s *sqlDB) exec(query string, args ...any) (sql.Result, error) {
+	return s.db.Exec(s.dialect.Rebind(query), args...)
+}
+
+func (s *sqlDB) query(query string, args ...any) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.Rebind(query), args...)
+}
+
+func (s *sqlDB) queryRow(query string, args ...any) *sql.Row {
+	return s.db.QueryRow(s.dialect.Rebind(query), args...)
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type sqlUsers struct {
+	*sqlDB
+}
+
+func (s *sqlUsers) Create(user *models.User) error {
+	query := `INSERT INTO users (username, email, password, confirmation_token, is_confirmed) VALUES (?, ?, ?, ?, ?) RETURNING id`
+	err := s.queryRow(query, user.Username, user.Email, user.Password, user.ConfirmationToken, user.IsConfirmed).Scan(&user.ID)
+	if s.dialect.IsUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *sqlUsers) getOne(where string, args ...any) (*models.User, error) {
+	query := `SELECT id, username, email, password, is_confirmed, is_admin FROM users WHERE ` + where
+	row := s.queryRow(query, args...)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsConfirmed, &user.IsAdmin)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &user, nil
+}
+
+func (s *sqlUsers) GetByID(userID int) (*models.User, error) {
+	return s.getOne(`id = ?`, userID)
+}
+
+func (s *sqlUsers) GetByUsername(username string) (*models.User, error) {
+	return s.getOne(`username = ?`, username)
+}
+
+func (s *sqlUsers) GetByEmail(email string) (*models.User, error) {
+	return s.getOne(`email = ?`, email)
+}
+
+func (s *sqlUsers) GetByResetToken(token string) (*models.User, error) {
+	return s.getOne(`reset_token = ? AND reset_token_expiry > ?`, token, time.Now())
+}
+
+func (s *sqlUsers) Confirm(token string) error {
+	query := `UPDATE users SET is_confirmed = TRUE, confirmation_token = NULL WHERE confirmation_token = ?`
+	result, err := s.exec(query, token)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (s *sqlUsers) UpdateProfile(userID int, username, email string) error {
+	query := `UPDATE users SET username = COALESCE(NULLIF(?, ''), username), email = COALESCE(NULLIF(?, ''), email) WHERE id = ?`
+	_, err := s.exec(query, username, email, userID)
+	if s.dialect.IsUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *sqlUsers) UpdatePassword(userID int, password string) error {
+	query := `UPDATE users SET password = ?, reset_token = NULL, reset_token_expiry = NULL WHERE id = ?`
+	_, err := s.exec(query, password, userID)
+	return err
+}
+
+func (s *sqlUsers) SaveResetToken(userID int, token string, expiry time.Time) error {
+	query := `UPDATE users SET reset_token = ?, reset_token_expiry = ? WHERE id = ?`
+	_, err := s.exec(query, token, expiry, userID)
+	return err
+}
+
+func (s *sqlUsers) SetAdmin(userID int, admin bool) error {
+	result, err := s.exec(`UPDATE users SET is_admin = ? WHERE id = ?`, admin, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+type sqlImages struct {
+	*sqlDB
+}
+
+func (s *sqlImages) Create(image *models.Image) error {
+	edits, err := encodeEdits(image.Edits)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	image.CreatedAt = time.Now().UTC()
+	query := `
+        INSERT INTO images (user_id, file_path, original_path, overlay, layout, created_at, captured_at, edits)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+        RETURNING id
+    `
+	err = tx.QueryRow(s.dialect.Rebind(query), image.UserID, image.FilePath, image.OriginalPath, image.Overlay, image.Layout, image.CreatedAt, nullableTime(image.CapturedAt), edits).Scan(&image.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.insertRenditions(tx, image.ID, image.Renditions); err != nil {
+		return err
+	}
+	frameQuery := s.dialect.Rebind(`INSERT INTO image_frames (image_id, position, file_path) VALUES (?, ?, ?)`)
+	for _, frame := range image.Frames {
+		if _, err := tx.Exec(frameQuery, image.ID, frame.Position, frame.FilePath); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlImages) UpdateRender(image *models.Image) error {
+	edits, err := encodeEdits(image.Edits)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE images SET file_path = ?, overlay = ?, edits = ? WHERE id = ?`
+	result, err := tx.Exec(s.dialect.Rebind(query), image.FilePath, image.Overlay, edits, image.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(s.dialect.Rebind(`DELETE FROM image_renditions WHERE image_id = ?`), image.ID); err != nil {
+		return err
+	}
+	if err := s.insertRenditions(tx, image.ID, image.Renditions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlImages) insertRenditions(tx *sql.Tx, imageID int, renditions []models.Rendition) error {
+	query := s.dialect.Rebind(`INSERT INTO image_renditions (image_id, name, file_path, width) VALUES (?, ?, ?, ?)`)
+	for _, rendition := range renditions {
+		if _, err := tx.Exec(query, imageID, rendition.Name, rendition.FilePath, rendition.Width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeEdits stores edits as JSON, and no edits as an empty string.
+func encodeEdits(edits models.Edits) (string, error) {
+	if edits.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(edits)
+	return string(data), err
+}
+
+func decodeEdits(data string, edits *models.Edits) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), edits)
+}
+
+func (s *sqlImages) GetByID(imageID int) (*models.Image, error) {
+	query := `SELECT id, file_path, original_path, overlay, layout, user_id, edits FROM images WHERE id = ?`
+	row := s.queryRow(query, imageID)
+
+	var image models.Image
+	var edits string
+	err := row.Scan(&image.ID, &image.FilePath, &image.OriginalPath, &image.Overlay, &image.Layout, &image.UserID, &edits)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &image, decodeEdits(edits, &image.Edits)
+}
+
+func (s *sqlImages) GetAuthor(imageID int) (*models.User, error) {
+	query := `
+        SELECT users.id, users.username, users.email, users.notify_on_comment
+        FROM images
+        JOIN users ON images.user_id = users.id
+        WHERE images.id = ?
+    `
+	row := s.queryRow(query, imageID)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.NotifyOnComment)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (s *sqlImages) ListPage(viewerID int, after Cursor, limit int) ([]models.Image, error) {
+	where := ""
+	var args []any
+	if !after.IsZero() {
+		createdAt := s.dialect.Timestamp("images.created_at")
+		bound := s.dialect.Timestamp("?")
+		where = `WHERE ` + createdAt + ` < ` + bound + ` OR (` + createdAt + ` = ` + bound + ` AND images.id < ?)`
+		args = append(args, after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	return s.listDetailed(viewerID, where, args, limit)
+}
+
+func (s *sqlImages) Get(viewerID, imageID int) (*models.Image, error) {
+	images, err := s.listDetailed(viewerID, `WHERE images.id = ?`, []any{imageID}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, ErrNotFound
+	}
+	return &images[0], nil
+}
+
+// listDetailed loads images with their author, renditions, comments and like
+// counts, newest first.
+func (s *sqlImages) listDetailed(viewerID int, where string, whereArgs []any, limit int) ([]models.Image, error) {
+	args := append([]any{viewerID}, whereArgs...)
+	args = append(args, limit)
+
+	query := `
+        SELECT 
+            images.id, 
+            images.user_id, 
+            users.username,
+            images.file_path, 
+            images.created_at,
+            images.captured_at,
+            images.original_path,
+            images.overlay,
+            images.layout,
+            images.edits,
+			images.user_id = ? AS is_owner
+        FROM images
+        JOIN users ON images.user_id = users.id
+        ` + where + `
+        ORDER BY ` + s.dialect.Timestamp("images.created_at") + ` DESC, images.id DESC
+		LIMIT ?
+    `
+
+	images, err := s.scanImages(query, func(rows *sql.Rows, image *models.Image) error {
+		var capturedAt sql.NullTime
+		var edits string
+		if err := rows.Scan(&image.ID, &image.UserID, &image.Username, &image.FilePath, &image.CreatedAt, &capturedAt, &image.OriginalPath, &image.Overlay, &image.Layout, &edits, &image.IsOwner); err != nil {
+			return err
+		}
+		image.CapturedAt = capturedAt.Time
+		return decodeEdits(edits, &image.Edits)
+	}, args...)
+	if err != nil {
+		log.Printf("Error fetching images: %v", err)
+		return nil, err
+	}
+
+	if err := s.attachRenditions(images); err != nil {
+		log.Printf("Error fetching renditions: %v", err)
+		return nil, err
+	}
+	if err := s.attachFrames(images); err != nil {
+		log.Printf("Error fetching frames: %v", err)
+		return nil, err
+	}
+	if err := s.attachComments(images); err != nil {
+		log.Printf("Error fetching comments: %v", err)
+		return nil, err
+	}
+	if err := s.attachLikes(images); err != nil {
+		log.Printf("Error fetching likes: %v", err)
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (s *sqlImages) ListRecentByUser(userID, limit int) ([]models.Image, error) {
+	query := `
+		SELECT images.id, images.user_id, images.file_path, images.created_at
+		FROM images
+		WHERE images.user_id = ?
+		ORDER BY ` + s.dialect.Timestamp("images.created_at") + ` DESC, images.id DESC
+		LIMIT ?
+	`
+
+	images, err := s.scanImages(query, func(rows *sql.Rows, image *models.Image) error {
+		return rows.Scan(&image.ID, &image.UserID, &image.FilePath, &image.CreatedAt)
+	}, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachRenditions(images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (s *sqlImages) scanImages(query string, scan func(*sql.Rows, *models.Image) error, args ...any) ([]models.Image, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []models.Image{}
+	for rows.Next() {
+		image := models.Image{Renditions: []models.Rendition{}, Comments: []models.Comment{}}
+		if err := scan(rows, &image); err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	return images, rows.Err()
+}
+
+// byID indexes images by ID and returns the IDs as query arguments together
+// with a matching "?, ?, ..." placeholder list for an IN clause.
+func byID(images []models.Image) (map[int]*models.Image, []any, string) {
+	index := make(map[int]*models.Image, len(images))
+	args := make([]any, len(images))
+	for i := range images {
+		index[images[i].ID] = &images[i]
+		args[i] = images[i].ID
+	}
+	return index, args, strings.TrimSuffix(strings.Repeat("?, ", len(images)), ", ")
+}
+
+func (s *sqlImages) attachRenditions(images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	index, args, placeholders := byID(images)
+
+	query := `
+        SELECT image_id, name, file_path, width
+        FROM image_renditions
+        WHERE image_id IN (` + placeholders + `)
+        ORDER BY image_id, width ASC
+    `
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var imageID int
+		var rendition models.Rendition
+		if err := rows.Scan(&imageID, &rendition.Name, &rendition.FilePath, &rendition.Width); err != nil {
+			return err
+		}
+		if image, ok := index[imageID]; ok {
+			image.Renditions = append(image.Renditions, rendition)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *sqlImages) attachFrames(images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	index, args, placeholders := byID(images)
+
+	query := `
+        SELECT image_id, position, file_path
+        FROM image_frames
+        WHERE image_id IN (` + placeholders + `)
+        ORDER BY image_id, position ASC
+    `
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var imageID int
+		var frame models.Frame
+		if err := rows.Scan(&imageID, &frame.Position, &frame.FilePath); err != nil {
+			return err
+		}
+		if image, ok := index[imageID]; ok {
+			image.Frames = append(image.Frames, frame)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *sqlImages) attachComments(images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	index, args, placeholders := byID(images)
+
+	query := `
+        SELECT 
+            comments.id, 
+            comments.image_id, 
+            comments.user_id, 
+            users.username, 
+            comments.content, 
+            comments.created_at
+        FROM comments
+        JOIN users ON comments.user_id = users.id
+        WHERE comments.image_id IN (` + placeholders + `)
+        ORDER BY comments.created_at ASC, comments.id ASC
+    `
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment models.Comment
+		if err := rows.Scan(&comment.ID, &comment.ImageID, &comment.UserID, &comment.Username, &comment.Content, &comment.CreatedAt); err != nil {
+			return err
+		}
+		if image, ok := index[comment.ImageID]; ok {
+			image.Comments = append(image.Comments, comment)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *sqlImages) attachLikes(images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	index, args, placeholders := byID(images)
+
+	query := `
+        SELECT image_id, COUNT(*)
+        FROM likes
+        WHERE image_id IN (` + placeholders + `)
+        GROUP BY image_id
+    `
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var imageID, count int
+		if err := rows.Scan(&imageID, &count); err != nil {
+			return err
+		}
+		if image, ok := index[imageID]; ok {
+			image.Likes = count
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *sqlImages) Renditions(imageID int) ([]models.Rendition, error) {
+	query := `
+        SELECT name, file_path, width
+        FROM image_renditions
+        WHERE image_id = ?
+        ORDER BY width ASC
+    `
+
+	rows, err := s.query(query, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	renditions := []models.Rendition{}
+	for rows.Next() {
+		var rendition models.Rendition
+		if err := rows.Scan(&rendition.Name, &rendition.FilePath, &rendition.Width); err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, rendition)
+	}
+
+	return renditions, nil
+}
+
+func (s *sqlImages) Frames(imageID int) ([]models.Frame, error) {
+	query := `
+        SELECT position, file_path
+        FROM image_frames
+        WHERE image_id = ?
+        ORDER BY position ASC
+    `
+
+	rows, err := s.query(query, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	frames := []models.Frame{}
+	for rows.Next() {
+		var frame models.Frame
+		if err := rows.Scan(&frame.Position, &frame.FilePath); err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames, rows.Err()
+}
+
+func (s *sqlImages) Delete(imageID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM comments WHERE image_id = ?`,
+		`DELETE FROM likes WHERE image_id = ?`,
+		`DELETE FROM image_renditions WHERE image_id = ?`,
+		`DELETE FROM image_frames WHERE image_id = ?`,
+		`DELETE FROM images WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(s.dialect.Rebind(query), imageID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type sqlComments struct {
+	*sqlDB
+}
+
+func (s *sqlComments) Add(imageID, userID int, content string) (int, error) {
+	var commentID int
+	query := `INSERT INTO comments (image_id, user_id, content, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP) RETURNING id`
+	err := s.queryRow(query, imageID, userID, content).Scan(&commentID)
+	return commentID, err
+}
+
+func (s *sqlComments) Get(commentID int) (*models.Comment, error) {
+	query := `
+        SELECT 
+            comments.id, 
+            comments.image_id, 
+            comments.user_id, 
+            users.username, 
+            comments.content, 
+            comments.created_at
+        FROM comments
+        JOIN users ON comments.user_id = users.id
+        WHERE comments.id = ?
+    `
+
+	var comment models.Comment
+	err := s.queryRow(query, commentID).Scan(&comment.ID, &comment.ImageID, &comment.UserID, &comment.Username, &comment.Content, &comment.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &comment, nil
+}
+
+func (s *sqlComments) Delete(commentID int) error {
+	result, err := s.exec(`DELETE FROM comments WHERE id = ?`, commentID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *sqlComments) ListByImage(imageID int) ([]models.Comment, error) {
+	query := `
+        SELECT 
+            comments.id, 
+            comments.image_id, 
+            comments.user_id, 
+            users.username, 
+            comments.content, 
+            comments.created_at
+        FROM comments
+        JOIN users ON comments.user_id = users.id
+        WHERE comments.image_id = ?
+        ORDER BY comments.created_at ASC
+    `
+
+	rows, err := s.query(query, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		if err := rows.Scan(&comment.ID, &comment.ImageID, &comment.UserID, &comment.Username, &comment.Content, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
+
+type sqlLikes struct {
+	*sqlDB
+}
+
+func (s *sqlLikes) Add(userID, imageID int) error {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM likes WHERE user_id = ? AND image_id = ?)`
+	err := s.queryRow(query, userID, imageID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrLikeExists
+	}
+
+	query = `INSERT INTO likes (user_id, image_id, created_at) VALUES (?, ?, ?)`
+	_, err = s.exec(query, userID, imageID, time.Now())
+	return err
+}
+
+func (s *sqlLikes) Remove(userID, imageID int) error {
+	result, err := s.exec(`DELETE FROM likes WHERE user_id = ? AND image_id = ?`, userID, imageID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+type sqlOutbox struct {
+	*sqlDB
+}
+
+func (s *sqlOutbox) Enqueue(email *models.OutboxEmail) error {
+	now := time.Now().UTC()
+	email.Status = models.OutboxPending
+	email.CreatedAt = now
+	if email.NextAttemptAt.IsZero() {
+		email.NextAttemptAt = now
+	}
+
+	query := `
+        INSERT INTO email_outbox (recipient, subject, text_body, html_body, status, next_attempt_at, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+        RETURNING id
+    `
+	return s.queryRow(query, email.To, email.Subject, email.Text, email.HTML, email.Status, email.NextAttemptAt.UTC(), email.CreatedAt).Scan(&email.ID)
+}
+
+func (s *sqlOutbox) Claim(now, leaseUntil time.Time, limit int) ([]models.OutboxEmail, error) {
+	query := `
+        SELECT id, recipient, subject, text_body, html_body, attempts, next_attempt_at, created_at
+        FROM email_outbox
+        WHERE status = ? AND next_attempt_at <= ?
+        ORDER BY next_attempt_at ASC, id ASC
+        LIMIT ?
+    `
+
+	rows, err := s.query(query, models.OutboxPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var due []models.OutboxEmail
+	for rows.Next() {
+		email := models.OutboxEmail{Status: models.OutboxPending}
+		if err := rows.Scan(&email.ID, &email.To, &email.Subject, &email.Text, &email.HTML, &email.Attempts, &email.NextAttemptAt, &email.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, email)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	claimed := []models.OutboxEmail{}
+	for _, email := range due {
+		query := `
+            UPDATE email_outbox
+            SET attempts = attempts + 1, next_attempt_at = ?
+            WHERE id = ? AND status = ? AND attempts = ?
+        `
+		result, err := s.exec(query, leaseUntil.UTC(), email.ID, models.OutboxPending, email.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			continue
+		}
+
+		email.Attempts++
+		email.NextAttemptAt = leaseUntil.UTC()
+		claimed = append(claimed, email)
+	}
+
+	return claimed, nil
+}
+
+func (s *sqlOutbox) MarkSent(emailID int, sentAt time.Time) error {
+	query := `UPDATE email_outbox SET status = ?, sent_at = ?, last_error = NULL WHERE id = ?`
+	_, err := s.exec(query, models.OutboxSent, sentAt.UTC(), emailID)
+	return err
+}
+
+func (s *sqlOutbox) MarkFailed(emailID int, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := models.OutboxPending
+	if dead {
+		status = models.OutboxDead
+	}
+
+	query := `UPDATE email_outbox SET status = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`
+	_, err := s.exec(query, status, lastError, nextAttemptAt.UTC(), emailID)
+	return err
+}
+
+type sqlSessions struct {
+	*sqlDB
+}
+
+func nullableID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func nullableTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+func (s *sqlSessions) Create(session *models.Session) error {
+	query := `
+        INSERT INTO sessions (token_hash, user_id, data, user_agent, ip, created_at, last_seen_at, expires_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+        RETURNING id
+    `
+	return s.queryRow(query, session.TokenHash, nullableID(session.UserID), session.Data, session.UserAgent, session.IP,
+		session.CreatedAt.UTC(), session.LastSeenAt.UTC(), session.ExpiresAt.UTC()).Scan(&session.ID)
+}
+
+func (s *sqlSessions) GetByTokenHash(tokenHash string) (*models.Session, error) {
+	query := `
+        SELECT id, token_hash, user_id, data, user_agent, ip, created_at, last_seen_at, expires_at
+        FROM sessions
+        WHERE token_hash = ? AND expires_at > ?
+    `
+	var session models.Session
+	var userID sql.NullInt64
+	err := s.queryRow(query, tokenHash, time.Now().UTC()).Scan(&session.ID, &session.TokenHash, &userID, &session.Data,
+		&session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	session.UserID = int(userID.Int64)
+	return &session, nil
+}
+
+func (s *sqlSessions) Update(session *models.Session) error {
+	query := `UPDATE sessions SET user_id = ?, data = ?, last_seen_at = ?, expires_at = ? WHERE id = ?`
+	_, err := s.exec(query, nullableID(session.UserID), session.Data, session.LastSeenAt.UTC(), session.ExpiresAt.UTC(), session.ID)
+	return err
+}
+
+func (s *sqlSessions) Touch(sessionID int, lastSeenAt time.Time, ip, userAgent string) error {
+	query := `UPDATE sessions SET last_seen_at = ?, ip = ?, user_agent = ? WHERE id = ?`
+	_, err := s.exec(query, lastSeenAt.UTC(), ip, userAgent, sessionID)
+	return err
+}
+
+func (s *sqlSessions) ListByUser(userID int) ([]models.Session, error) {
+	query := `
+        SELECT id, user_agent, ip, created_at, last_seen_at, expires_at
+        FROM sessions
+        WHERE user_id = ? AND expires_at > ?
+        ORDER BY last_seen_at DESC, id DESC
+    `
+	rows, err := s.query(query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session := models.Session{UserID: userID}
+		if err := rows.Scan(&session.ID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *sqlSessions) Delete(sessionID int) error {
+	_, err := s.exec(`DELETE FROM sessions WHERE id = ?`, sessionID)
+	return err
+}
+
+func (s *sqlSessions) DeleteForUser(userID, sessionID int) error {
+	result, err := s.exec(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *sqlSessions) DeleteAllForUser(userID, exceptID int) error {
+	_, err := s.exec(`DELETE FROM sessions WHERE user_id = ? AND id <> ?`, userID, exceptID)
+	return err
+}
+
+func (s *sqlSessions) DeleteExpired(now time.Time) (int64, error) {
+	result, err := s.exec(`DELETE FROM sessions WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+type sqlTwoFactor struct {
+	*sqlDB
+}
+
+func (s *sqlTwoFactor) Get(userID int) (*models.TwoFactor, error) {
+	query := `SELECT user_id, secret, enabled, last_step, created_at FROM two_factor WHERE user_id = ?`
+	var twoFactor models.TwoFactor
+	err := s.queryRow(query, userID).Scan(&twoFactor.UserID, &twoFactor.Secret, &twoFactor.Enabled, &twoFactor.LastStep, &twoFactor.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &twoFactor, nil
+}
+
+func (s *sqlTwoFactor) SavePending(userID int, secret string) error {
+	query := `
+        INSERT INTO two_factor (user_id, secret, enabled, last_step, created_at)
+        VALUES (?, ?, FALSE, 0, ?)
+        ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, last_step = 0, created_at = excluded.created_at
+        WHERE two_factor.enabled = FALSE
+    `
+	result, err := s.exec(query, userID, secret, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *sqlTwoFactor) Enable(userID int, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(s.dialect.Rebind(`UPDATE two_factor SET enabled = TRUE WHERE user_id = ? AND enabled = FALSE`), userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrNotFound
+	}
+
+	if err := replaceRecoveryCodes(tx, s.dialect, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, d dialect.Dialect, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(d.Rebind(`DELETE FROM recovery_codes WHERE user_id = ?`), userID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec(d.Rebind(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`), userID, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlTwoFactor) Disable(userID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, s.dialect, userID, nil); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.dialect.Rebind(`DELETE FROM two_factor WHERE user_id = ?`), userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlTwoFactor) UseStep(userID int, step int64) error {
+	query := `UPDATE two_factor SET last_step = ? WHERE user_id = ? AND last_step < ?`
+	result, err := s.exec(query, step, userID, step)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *sqlTwoFactor) UseRecoveryCode(userID int, codeHash string) error {
+	query := `UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+	result, err := s.exec(query, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *sqlTwoFactor) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := s.queryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+type sqlIdentities struct {
+	*sqlDB
+}
+
+func (s *sqlIdentities) Get(provider, subject string) (*models.Identity, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at FROM identities WHERE provider = ? AND subject = ?`
+	var identity models.Identity
+	err := s.queryRow(query, provider, subject).Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &identity, nil
+}
+
+func (s *sqlIdentities) Create(identity *models.Identity) error {
+	identity.CreatedAt = time.Now().UTC()
+	query := `INSERT INTO identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id`
+	err := s.queryRow(query, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt).Scan(&identity.ID)
+	if s.dialect.IsUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *sqlIdentities) ListByUser(userID int) ([]models.Identity, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at FROM identities WHERE user_id = ? ORDER BY created_at, id`
+	rows, err := s.query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.Identity{}
+	for rows.Next() {
+		var identity models.Identity
+		if err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (s *sqlIdentities) Delete(userID, identityID int) error {
+	result, err := s.exec(`DELETE FROM identities WHERE id = ? AND user_id = ?`, identityID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+type sqlAPITokens struct {
+	*sqlDB
+}
+
+func (s *sqlAPITokens) Create(token *models.APIToken) error {
+	token.CreatedAt = time.Now().UTC()
+	query := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id`
+	return s.queryRow(query, token.UserID, token.Name, token.TokenHash, strings.Join(token.Scopes, " "), token.CreatedAt).Scan(&token.ID)
+}
+
+func scanAPIToken(scan func(dest ...any) error) (*models.APIToken, error) {
+	var token models.APIToken
+	var scopes string
+	var lastUsedAt sql.NullTime
+	if err := scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &scopes, &token.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	token.LastUsedAt = lastUsedAt.Time
+	return &token, nil
+}
+
+func (s *sqlAPITokens) GetByHash(tokenHash string) (*models.APIToken, error) {
+	query := `SELECT id, user_id, name, token_hash, scopes, created_at, last_used_at FROM api_tokens WHERE token_hash = ?`
+	token, err := scanAPIToken(s.queryRow(query, tokenHash).Scan)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return token, nil
+}
+
+func (s *sqlAPITokens) ListByUser(userID int) ([]models.APIToken, error) {
+	query := `SELECT id, user_id, name, token_hash, scopes, created_at, last_used_at FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC, id DESC`
+	rows, err := s.query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *sqlAPITokens) Touch(tokenID int, lastUsedAt time.Time) error {
+	_, err := s.exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, lastUsedAt.UTC(), tokenID)
+	return err
+}
+
+func (s *sqlAPITokens) Delete(userID, tokenID int) error {
+	result, err := s.exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+type sqlOverlays struct {
+	*sqlDB
+}
+
+func (s *sqlOverlays) Create(overlay *models.Overlay) error {
+	overlay.CreatedAt = time.Now().UTC()
+	query := `
+        INSERT INTO overlays (slug, name, category, file_path, enabled, sort_order, created_at)
+        VALUES (?, ?, ?, ?, ?, (SELECT COALESCE(MAX(sort_order), -1) + 1 FROM overlays), ?)
+        RETURNING id, sort_order
+    `
+	err := s.queryRow(query, overlay.Slug, overlay.Name, overlay.Category, overlay.FilePath, overlay.Enabled, overlay.CreatedAt).Scan(&overlay.ID, &overlay.SortOrder)
+	if s.dialect.IsUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+const overlayColumns = `id, slug, name, category, file_path, enabled, sort_order, created_at`
+
+func scanOverlay(scan func(dest ...any) error) (*models.Overlay, error) {
+	var overlay models.Overlay
+	if err := scan(&overlay.ID, &overlay.Slug, &overlay.Name, &overlay.Category, &overlay.FilePath, &overlay.Enabled, &overlay.SortOrder, &overlay.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &overlay, nil
+}
+
+func (s *sqlOverlays) GetBySlug(slug string) (*models.Overlay, error) {
+	overlay, err := scanOverlay(s.queryRow(`SELECT `+overlayColumns+` FROM overlays WHERE slug = ?`, slug).Scan)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return overlay, nil
+}
+
+func (s *sqlOverlays) List(includeDisabled bool) ([]models.Overlay, error) {
+	query := `SELECT ` + overlayColumns + ` FROM overlays`
+	if !includeDisabled {
+		query += ` WHERE enabled = TRUE`
+	}
+	query += ` ORDER BY sort_order, id`
+
+	rows, err := s.query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overlays := []models.Overlay{}
+	for rows.Next() {
+		overlay, err := scanOverlay(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		overlays = append(overlays, *overlay)
+	}
+	return overlays, rows.Err()
+}
+
+func (s *sqlOverlays) SetEnabled(slug string, enabled bool) error {
+	result, err := s.exec(`UPDATE overlays SET enabled = ? WHERE slug = ?`, enabled, slug)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Reorder moves the given overlays to the front in the given order. Overlays
+// that aren't listed keep their relative order after them.
+func (s *sqlOverlays) Reorder(slugs []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := s.dialect.Rebind(`UPDATE overlays SET sort_order = sort_order + ?`)
+	if _, err := tx.Exec(query, len(slugs)); err != nil {
+		return err
+	}
+
+	query = s.dialect.Rebind(`UPDATE overlays SET sort_order = ? WHERE slug = ?`)
+	for i, slug := range slugs {
+		result, err := tx.Exec(query, i, slug)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return ErrNotFound
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"photo-booth.com/internal/models"
+)
+
+// queryCount counts the statements sent through the "counting-" drivers.
+var (
+	queryCount     atomic.Int64
+	registerDriver sync.Mutex
+	registered     = map[string]bool{}
+)
+
+type countingDriver struct {
+	driver.Driver
+}
+
+func (d countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	queryCount.Add(1)
+	return c.Conn.Prepare(query)
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	queryCount.Add(1)
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	queryCount.Add(1)
+	return execer.ExecContext(ctx, query, args)
+}
+
+// openCountingDB opens a migrated test database through a driver that counts
+// every statement.
+func openCountingDB(tb testing.TB) Stores {
+	tb.Helper()
+	d, dsn := testDatabase(tb)
+
+	name := "counting-" + d.Driver
+	registerDriver.Lock()
+	if !registered[name] {
+		plain, err := sql.Open(d.Driver, dsn)
+		if err != nil {
+			registerDriver.Unlock()
+			tb.Fatal(err)
+		}
+		sql.Register(name, countingDriver{plain.Driver()})
+		plain.Close()
+		registered[name] = true
+	}
+	registerDriver.Unlock()
+
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return NewSQL(db, d)
+}
+
+// seedFeed fills the feed with images that each have renditions, a frame, a
+// comment and a like.
+func seedFeed(tb testing.TB, stores Stores, images int) *models.User {
+	tb.Helper()
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "hash"}
+	if err := stores.Users.Create(user); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < images; i++ {
+		image := &models.Image{
+			UserID:   user.ID,
+			FilePath: fmt.Sprintf("photo_%d.jpg", i),
+			Renditions: []models.Rendition{
+				{Name: models.RenditionThumbnail, FilePath: fmt.Sprintf("photo_%d_thumbnail.jpg", i), Width: 320},
+				{Name: models.RenditionOriginal, FilePath: fmt.Sprintf("photo_%d.jpg", i), Width: 1280},
+			},
+			Frames: []models.Frame{{Position: 0, FilePath: fmt.Sprintf("frames/photo_%d.jpg", i)}},
+		}
+		if err := stores.Images.Create(image); err != nil {
+			tb.Fatal(err)
+		}
+		if _, err := stores.Comments.Add(image.ID, user.ID, "Nice!"); err != nil {
+			tb.Fatal(err)
+		}
+		if err := stores.Likes.Add(user.ID, image.ID); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return user
+}
+
+var pageSizes = []int{1, 20, 100}
+
+// TestListPageQueryCount checks that loading a gallery page takes the same
+// number of queries however many images are on it.
+func TestListPageQueryCount(t *testing.T) {
+	stores := openCountingDB(t)
+	user := seedFeed(t, stores, 100)
+
+	counts := map[int]int64{}
+	for _, size := range pageSizes {
+		queryCount.Store(0)
+		images, err := stores.Images.ListPage(user.ID, Cursor{}, size)
+		if err != nil {
+			t.Fatalf("ListPage(%d): %v", size, err)
+		}
+		if len(images) != size {
+			t.Fatalf("ListPage(%d) returned %d images", size, len(images))
+		}
+		last := images[size-1]
+		if len(last.Renditions) != 2 || len(last.Frames) != 1 || len(last.Comments) != 1 || last.Likes != 1 {
+			t.Errorf("ListPage(%d): last image is missing details: %+v", size, last)
+		}
+		counts[size] = queryCount.Load()
+	}
+
+	if counts[pageSizes[0]] == 0 {
+		t.Fatal("no queries were counted")
+	}
+	for _, size := range pageSizes[1:] {
+		if counts[size] != counts[pageSizes[0]] {
+			t.Errorf("queries per page = %v, want the same for every page size", counts)
+			break
+		}
+	}
+}
+
+func BenchmarkListPage(b *testing.B) {
+	stores := openCountingDB(b)
+	user := seedFeed(b, stores, 100)
+
+	for _, size := range pageSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			queryCount.Store(0)
+			for i := 0; i < b.N; i++ {
+				if _, err := stores.Images.ListPage(user.ID, Cursor{}, size); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(queryCount.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"photo-booth.com/internal/dialect"
+	"photo-booth.com/internal/migrations"
+	"photo-booth.com/internal/models"
+)
+
+// testDatabase prepares a migrated database for one test and returns its
+// dialect and data source name. It is a fresh SQLite file unless
+// DATABASE_URL points at PostgreSQL, where each test gets its own schema.
+func testDatabase(tb testing.TB) (dialect.Dialect, string) {
+	tb.Helper()
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if !strings.HasPrefix(databaseURL, "postgres://") && !strings.HasPrefix(databaseURL, "postgresql://") {
+		databaseURL = "sqlite://" + filepath.Join(tb.TempDir(), "test.db")
+	}
+	d, dsn, err := dialect.Parse(databaseURL)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if d == dialect.Postgres {
+		suffix := make([]byte, 6)
+		rand.Read(suffix)
+		schema := "test_" + hex.EncodeToString(suffix)
+
+		admin, err := sql.Open(d.Driver, dsn)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+			admin.Close()
+			tb.Fatalf("creating schema: %v", err)
+		}
+		tb.Cleanup(func() {
+			if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+				tb.Errorf("dropping schema: %v", err)
+			}
+			admin.Close()
+		})
+
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "search_path=" + schema
+	}
+
+	db, err := sql.Open(d.Driver, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := migrations.Up(db, d); err != nil {
+		tb.Fatalf("migrating: %v", err)
+	}
+	return d, dsn
+}
+
+func openTestDB(tb testing.TB) (*sql.DB, dialect.Dialect) {
+	tb.Helper()
+	d, dsn := testDatabase(tb)
+	db, err := sql.Open(d.Driver, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+func TestSQLStores(t *testing.T) {
+	db, d := openTestDB(t)
+	stores := NewSQL(db, d)
+	t.Logf("running against %s", d.Name)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: "hash", ConfirmationToken: "confirm-me"}
+	bob := &models.User{Username: "bob", Email: "bob@example.com", Password: "hash", IsConfirmed: true}
+	for _, user := range []*models.User{alice, bob} {
+		if err := stores.Users.Create(user); err != nil {
+			t.Fatalf("creating %s: %v", user.Username, err)
+		}
+	}
+
+	t.Run("users", func(t *testing.T) {
+		if alice.ID == 0 || bob.ID == alice.ID {
+			t.Fatalf("ids not assigned: alice %d, bob %d", alice.ID, bob.ID)
+		}
+		err := stores.Users.Create(&models.User{Username: "alice", Email: "other@example.com", Password: "hash"})
+		if !errors.Is(err, ErrDuplicate) {
+			t.Errorf("duplicate username: err = %v, want ErrDuplicate", err)
+		}
+		if _, err := stores.Users.GetByUsername("nobody"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := stores.Users.Confirm("confirm-me"); err != nil {
+			t.Fatalf("Confirm: %v", err)
+		}
+		user, err := stores.Users.GetByEmail("alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != alice.ID || !user.IsConfirmed {
+			t.Errorf("GetByEmail = %+v, want confirmed alice", user)
+		}
+	})
+
+	t.Run("images", func(t *testing.T) {
+		image := &models.Image{
+			UserID:   alice.ID,
+			FilePath: "photo_1.jpg",
+			Overlay:  "film-wide",
+			Layout:   "strip",
+			Edits:    models.Edits{Filter: "sepia", Layers: []models.Layer{{Type: models.LayerText, X: 0.5, Y: 0.9, Text: "Party?"}}},
+			Renditions: []models.Rendition{
+				{Name: models.RenditionThumbnail, FilePath: "photo_1_thumbnail.jpg", Width: 320},
+				{Name: models.RenditionOriginal, FilePath: "photo_1.jpg", Width: 800},
+			},
+			Frames: []models.Frame{{Position: 0, FilePath: "frames/photo_2.jpg"}, {Position: 1, FilePath: "frames/photo_3.jpg"}},
+		}
+		if err := stores.Images.Create(image); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		got, err := stores.Images.Get(alice.ID, image.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !got.IsOwner || got.Username != "alice" || got.Overlay != "film-wide" || got.Layout != "strip" || got.Edits.Filter != "sepia" {
+			t.Errorf("Get = %+v", got)
+		}
+		if len(got.Edits.Layers) != 1 || got.Edits.Layers[0].Text != "Party?" {
+			t.Errorf("layers = %+v", got.Edits.Layers)
+		}
+		author, err := stores.Images.GetAuthor(image.ID)
+		if err != nil || author.Username != "alice" {
+			t.Errorf("GetAuthor = %+v, %v", author, err)
+		}
+		if len(got.Renditions) != 2 || len(got.Frames) != 2 || got.Frames[1].FilePath != "frames/photo_3.jpg" {
+			t.Errorf("renditions = %+v, frames = %+v", got.Renditions, got.Frames)
+		}
+
+		if err := stores.Images.Delete(image.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := stores.Images.GetByID(image.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("after Delete: err = %v, want ErrNotFound", err)
+		}
+		if frames, err := stores.Images.Frames(image.ID); err != nil || len(frames) != 0 {
+			t.Errorf("frames after Delete = %v, %v", frames, err)
+		}
+	})
+
+	t.Run("feed", func(t *testing.T) {
+		var ids []int
+		for i := 0; i < 5; i++ {
+			image := &models.Image{UserID: bob.ID, FilePath: "photo.jpg"}
+			if err := stores.Images.Create(image); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, image.ID)
+		}
+		newest := ids[len(ids)-1]
+
+		if _, err := stores.Comments.Add(newest, alice.ID, "Nice!"); err != nil {
+			t.Fatalf("adding comment: %v", err)
+		}
+		if err := stores.Likes.Add(alice.ID, newest); err != nil {
+			t.Fatalf("adding like: %v", err)
+		}
+		if err := stores.Likes.Add(alice.ID, newest); !errors.Is(err, ErrLikeExists) {
+			t.Errorf("second like: err = %v, want ErrLikeExists", err)
+		}
+
+		first, err := stores.Images.ListPage(alice.ID, Cursor{}, 3)
+		if err != nil {
+			t.Fatalf("ListPage: %v", err)
+		}
+		if len(first) != 3 || first[0].ID != newest {
+			t.Fatalf("first page = %v, want 3 images starting with %d", imageIDs(first), newest)
+		}
+		if first[0].Likes != 1 || len(first[0].Comments) != 1 || first[0].Comments[0].Username != "alice" || first[0].IsOwner {
+			t.Errorf("newest image = %+v", first[0])
+		}
+
+		second, err := stores.Images.ListPage(alice.ID, CursorFor(first[2]), 3)
+		if err != nil {
+			t.Fatalf("ListPage: %v", err)
+		}
+		if len(second) != 2 || second[1].ID != ids[0] {
+			t.Errorf("second page = %v, want the 2 oldest images", imageIDs(second))
+		}
+
+		if err := stores.Likes.Remove(alice.ID, newest); err != nil {
+			t.Errorf("removing like: %v", err)
+		}
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		now := time.Now().UTC().Truncate(time.Second)
+		live := &models.Session{TokenHash: "live", UserID: alice.ID, Data: "data", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+		expired := &models.Session{TokenHash: "expired", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(-time.Hour)}
+		for _, session := range []*models.Session{live, expired} {
+			if err := stores.Sessions.Create(session); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+
+		got, err := stores.Sessions.GetByTokenHash("live")
+		if err != nil || got.ID != live.ID || got.UserID != alice.ID {
+			t.Errorf("GetByTokenHash = %+v, %v", got, err)
+		}
+		if deleted, err := stores.Sessions.DeleteExpired(now); err != nil || deleted != 1 {
+			t.Errorf("DeleteExpired = %d, %v, want 1", deleted, err)
+		}
+		if _, err := stores.Sessions.GetByTokenHash("expired"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expired session: err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("overlays", func(t *testing.T) {
+		for _, slug := range []string{"film-wide", "hearts", "confetti"} {
+			overlay := &models.Overlay{Slug: slug, Name: slug, Category: "Party", FilePath: "overlays/" + slug + ".png", Enabled: true}
+			if err := stores.Overlays.Create(overlay); err != nil {
+				t.Fatalf("Create %s: %v", slug, err)
+			}
+		}
+		if err := stores.Overlays.Create(&models.Overlay{Slug: "hearts", FilePath: "x.png"}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("duplicate slug: err = %v, want ErrDuplicate", err)
+		}
+		if err := stores.Overlays.Reorder([]string{"confetti"}); err != nil {
+			t.Fatalf("Reorder: %v", err)
+		}
+		if err := stores.Overlays.SetEnabled("hearts", false); err != nil {
+			t.Fatalf("SetEnabled: %v", err)
+		}
+
+		overlays, err := stores.Overlays.List(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var slugs []string
+		for _, overlay := range overlays {
+			slugs = append(slugs, overlay.Slug)
+		}
+		if strings.Join(slugs, ",") != "confetti,film-wide" {
+			t.Errorf("enabled overlays = %v, want confetti, film-wide", slugs)
+		}
+	})
+
+	t.Run("two-factor", func(t *testing.T) {
+		if err := stores.TwoFactor.SavePending(bob.ID, "SECRET"); err != nil {
+			t.Fatalf("SavePending: %v", err)
+		}
+		if err := stores.TwoFactor.Enable(bob.ID, []string{"code-1", "code-2"}); err != nil {
+			t.Fatalf("Enable: %v", err)
+		}
+		if err := stores.TwoFactor.SavePending(bob.ID, "OTHER"); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("SavePending while enabled: err = %v, want ErrDuplicate", err)
+		}
+		tf, err := stores.TwoFactor.Get(bob.ID)
+		if err != nil || !tf.Enabled || tf.Secret != "SECRET" {
+			t.Fatalf("Get = %+v, %v, want enabled with the first secret", tf, err)
+		}
+
+		// A time step is accepted once, and never after a later one.
+		for _, tt := range []struct {
+			step int64
+			err  error
+		}{{100, nil}, {100, ErrInvalidToken}, {99, ErrInvalidToken}, {101, nil}} {
+			if err := stores.TwoFactor.UseStep(bob.ID, tt.step); !errors.Is(err, tt.err) {
+				t.Errorf("UseStep(%d): err = %v, want %v", tt.step, err, tt.err)
+			}
+		}
+
+		if err := stores.TwoFactor.UseRecoveryCode(bob.ID, "code-1"); err != nil {
+			t.Errorf("UseRecoveryCode: %v", err)
+		}
+		if err := stores.TwoFactor.UseRecoveryCode(bob.ID, "code-1"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("reusing a recovery code: err = %v, want ErrInvalidToken", err)
+		}
+		if err := stores.TwoFactor.UseRecoveryCode(alice.ID, "code-2"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("another user's recovery code: err = %v, want ErrInvalidToken", err)
+		}
+		if count, err := stores.TwoFactor.CountRecoveryCodes(bob.ID); count != 1 || err != nil {
+			t.Errorf("CountRecoveryCodes = %d, %v, want 1", count, err)
+		}
+
+		if err := stores.TwoFactor.Disable(bob.ID); err != nil {
+			t.Fatalf("Disable: %v", err)
+		}
+		if _, err := stores.TwoFactor.Get(bob.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get after Disable: err = %v, want ErrNotFound", err)
+		}
+		if count, err := stores.TwoFactor.CountRecoveryCodes(bob.ID); count != 0 || err != nil {
+			t.Errorf("CountRecoveryCodes after Disable = %d, %v, want 0", count, err)
+		}
+	})
+}
+
+func imageIDs(images []models.Image) []int {
+	ids := make([]int, len(images))
+	for i, image := range images {
+		ids[i] = image.ID
+	}
+	return ids
+}
+
+// TestListPageLegacyTimestamps pages through images whose created_at was
+// filled in by SQLite's CURRENT_TIMESTAMP before the application set it, a
+// format the cursor's time doesn't compare equal to as text.
+func TestListPageLegacyTimestamps(t *testing.T) {
+	db, d := openTestDB(t)
+	if d != dialect.SQLite {
+		t.Skip("only SQLite databases hold legacy timestamps")
+	}
+	stores := NewSQL(db, d)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "hash"}
+	if err := stores.Users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	var want []int
+	for _, createdAt := range []string{"2024-01-01 10:00:00", "2024-01-01 10:00:00", "2024-01-02 09:30:00", "2024-01-03 08:15:00"} {
+		result, err := db.Exec(`INSERT INTO images (user_id, file_path, created_at) VALUES (?, 'photo.jpg', ?)`, user.ID, createdAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := result.LastInsertId()
+		want = append([]int{int(id)}, want...)
+	}
+
+	var got []int
+	cursor := Cursor{}
+	for page := 0; page <= len(want); page++ {
+		images, err := stores.Images.ListPage(user.ID, cursor, 2)
+		if err != nil {
+			t.Fatalf("ListPage: %v", err)
+		}
+		if len(images) == 0 {
+			break
+		}
+		got = append(got, imageIDs(images)...)
+		cursor = CursorFor(images[len(images)-1])
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+}
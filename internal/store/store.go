@@ -0,0 +1,131 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"photo-booth.com/internal/models"
+)
+
+var (
+	ErrNotFound     = errors.New("record not found")
+	ErrDuplicate    = errors.New("record already exists")
+	ErrLikeExists   = errors.New("like already exists")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+type UserStore interface {
+	Create(user *models.User) error
+	GetByID(userID int) (*models.User, error)
+	GetByUsername(username string) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	GetByResetToken(token string) (*models.User, error)
+	Confirm(token string) error
+	UpdateProfile(userID int, username, email string) error
+	UpdatePassword(userID int, password string) error
+	SaveResetToken(userID int, token string, expiry time.Time) error
+	SetAdmin(userID int, admin bool) error
+}
+
+type ImageStore interface {
+	// Create stores the image with its renditions and frames and sets its
+	// ID.
+	Create(image *models.Image) error
+	// UpdateRender replaces the rendered file, renditions, overlay and edits
+	// of an image after it was re-rendered from its original.
+	UpdateRender(image *models.Image) error
+	GetByID(imageID int) (*models.Image, error)
+	GetAuthor(imageID int) (*models.User, error)
+	Get(viewerID, imageID int) (*models.Image, error)
+	ListPage(viewerID int, after Cursor, limit int) ([]models.Image, error)
+	ListRecentByUser(userID, limit int) ([]models.Image, error)
+	Renditions(imageID int) ([]models.Rendition, error)
+	// Frames returns the captures a photo strip was composed from, in order.
+	Frames(imageID int) ([]models.Frame, error)
+	Delete(imageID int) error
+}
+
+type CommentStore interface {
+	Add(imageID, userID int, content string) (int, error)
+	Get(commentID int) (*models.Comment, error)
+	ListByImage(imageID int) ([]models.Comment, error)
+	Delete(commentID int) error
+}
+
+type LikeStore interface {
+	Add(userID, imageID int) error
+	Remove(userID, imageID int) error
+}
+
+// OutboxStore persists outgoing email. Claim leases due messages to the
+// caller until leaseUntil so that several workers never send the same one.
+type OutboxStore interface {
+	Enqueue(email *models.OutboxEmail) error
+	Claim(now, leaseUntil time.Time, limit int) ([]models.OutboxEmail, error)
+	MarkSent(emailID int, sentAt time.Time) error
+	MarkFailed(emailID int, lastError string, nextAttemptAt time.Time, dead bool) error
+}
+
+// SessionStore persists login sessions. Sessions are looked up by the hash of
+// the token in the cookie, so the table alone can't be used to hijack them.
+type SessionStore interface {
+	Create(session *models.Session) error
+	GetByTokenHash(tokenHash string) (*models.Session, error)
+	Update(session *models.Session) error
+	Touch(sessionID int, lastSeenAt time.Time, ip, userAgent string) error
+	ListByUser(userID int) ([]models.Session, error)
+	Delete(sessionID int) error
+	DeleteForUser(userID, sessionID int) error
+	DeleteAllForUser(userID, exceptID int) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// TwoFactorStore keeps TOTP secrets and hashed recovery codes. A secret is
+// pending until Enable is called after the user proved they can generate codes.
+type TwoFactorStore interface {
+	Get(userID int) (*models.TwoFactor, error)
+	SavePending(userID int, secret string) error
+	Enable(userID int, recoveryCodeHashes []string) error
+	Disable(userID int) error
+	UseStep(userID int, step int64) error
+	UseRecoveryCode(userID int, codeHash string) error
+	CountRecoveryCodes(userID int) (int, error)
+}
+
+type IdentityStore interface {
+	Get(provider, subject string) (*models.Identity, error)
+	Create(identity *models.Identity) error
+	ListByUser(userID int) ([]models.Identity, error)
+	Delete(userID, identityID int) error
+}
+
+type APITokenStore interface {
+	Create(token *models.APIToken) error
+	GetByHash(tokenHash string) (*models.APIToken, error)
+	ListByUser(userID int) ([]models.APIToken, error)
+	Touch(tokenID int, lastUsedAt time.Time) error
+	Delete(userID, tokenID int) error
+}
+
+// OverlayStore is the overlay catalog in display order. New overlays are
+// added at the end.
+type OverlayStore interface {
+	Create(overlay *models.Overlay) error
+	GetBySlug(slug string) (*models.Overlay, error)
+	List(includeDisabled bool) ([]models.Overlay, error)
+	SetEnabled(slug string, enabled bool) error
+	Reorder(slugs []string) error
+}
+
+type Stores struct {
+	Users      UserStore
+	Images     ImageStore
+	Comments   CommentStore
+	Likes      LikeStore
+	Outbox     OutboxStore
+	Sessions   SessionStore
+	TwoFactor  TwoFactorStore
+	Identities IdentityStore
+	APITokens  APITokenStore
+	Overlays   OverlayStore
+}
@@ -0,0 +1,116 @@
+package twofactor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	Issuer            = "Photo Booth"
+	RecoveryCodeCount = 10
+
+	period = 30
+	skew   = 1
+)
+
+// NewSecret returns a fresh base32 TOTP secret.
+func NewSecret(accountName string) (string, error) {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: Issuer, AccountName: accountName, Period: period})
+	if err != nil {
+		return "", err
+	}
+	return key.Secret(), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI authenticator apps scan.
+func ProvisioningURI(secret, accountName string) (string, error) {
+	key, err := newKey(secret, accountName)
+	if err != nil {
+		return "", err
+	}
+	return key.URL(), nil
+}
+
+// QRCode renders the provisioning URI as a PNG data URL.
+func QRCode(secret, accountName string) (string, error) {
+	key, err := newKey(secret, accountName)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func newKey(secret, accountName string) (*otp.Key, error) {
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return nil, err
+	}
+	return totp.Generate(totp.GenerateOpts{Issuer: Issuer, AccountName: accountName, Period: period, Secret: raw})
+}
+
+// Validate checks code against the steps around now and returns the matching
+// time step, so callers can refuse to accept the same code twice.
+func Validate(secret, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != otp.DigitsSix.Length() {
+		return 0, false
+	}
+
+	current := now.Unix() / period
+	for step := current - skew; step <= current+skew; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*period, 0), totp.ValidateOpts{
+			Period:    period,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes returns RecoveryCodeCount random one-time codes formatted
+// as xxxxx-xxxxx.
+func NewRecoveryCodes() []string {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		bytes := make([]byte, 7)
+		rand.Read(bytes)
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(bytes))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes
+}
+
+// HashRecoveryCode normalizes a recovery code as typed by the user and hashes
+// it for storage. The codes are random, so a plain SHA-256 is enough.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,108 @@
+package twofactor
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{Period: period, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := NewSecret("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 24, 12, 0, 10, 0, time.UTC)
+	step := now.Unix() / period
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current code", codeAt(t, secret, now), step, true},
+		{"previous code", codeAt(t, secret, now.Add(-period*time.Second)), step - 1, true},
+		{"next code", codeAt(t, secret, now.Add(period*time.Second)), step + 1, true},
+		{"expired code", codeAt(t, secret, now.Add(-2*period*time.Second)), 0, false},
+		{"spaced out", " " + codeAt(t, secret, now)[:3] + " " + codeAt(t, secret, now)[3:], step, true},
+		{"too short", codeAt(t, secret, now)[:5], 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := Validate(secret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	other, err := NewSecret("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(other, codeAt(t, secret, now), now); ok {
+		t.Error("code accepted for another secret")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret, err := NewSecret("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := ProvisioningURI(secret, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if parsed.Scheme != "otpauth" || query.Get("secret") != secret || query.Get("issuer") != Issuer || query.Get("period") != "30" {
+		t.Errorf("ProvisioningURI = %q", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := NewRecoveryCodes()
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("%d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q repeated", code)
+		}
+		seen[code] = true
+	}
+
+	hash := HashRecoveryCode(codes[0])
+	for _, typed := range []string{codes[0], " " + strings.ToUpper(codes[0]) + " ", codes[0][:5] + codes[0][6:]} {
+		if got := HashRecoveryCode(typed); got != hash {
+			t.Errorf("HashRecoveryCode(%q) differs from the code as issued", typed)
+		}
+	}
+	if HashRecoveryCode(codes[1]) == hash {
+		t.Error("different codes hash the same")
+	}
+}